@@ -0,0 +1,38 @@
+// Package assets embeds the static files of the translate element into the
+// binary, so that it doesn't depend on the directory it is started from.
+package assets
+
+import "embed"
+
+// FS holds the static/v1 tree, at the same paths as it is served under.
+//
+//go:embed static/v1
+var FS embed.FS
+
+// ScriptRewrite replaces a Google specific value of a script.
+type ScriptRewrite struct {
+	// Old is the value as downloaded from Google.
+	Old string
+	// New is the value it is replaced with. {origin}, {scheme} and {host} are
+	// replaced with those of the origin the script is served from.
+	New string
+}
+
+// ScriptRewrites are the values of the translate element scripts, by file,
+// rewritten for the origins the service is configured with. Updated scripts
+// must still contain all of them.
+var ScriptRewrites = map[string][]ScriptRewrite{
+	"static/v1/element.js": {
+		// host translate requests are sent to
+		{Old: `const h='translate.googleapis.com'`, New: `const h='{host}'`},
+		{Old: `c._pas=s;`, New: `c._pas='{scheme}://';`},
+		{
+			Old: `c._ps=b+staticPath+'css\/translateelement.css'`,
+			New: `c._ps='{origin}/static/v1/css/translateelement.css'`,
+		},
+		{
+			Old: `c._cjlc('https:\/\/translate.googleapis.com\/translate_static\/js\/element\/main.js')`,
+			New: `c._cjlc('{origin}/static/v1/js/element/main.js')`,
+		},
+	},
+}
@@ -0,0 +1,30 @@
+package assets_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/assets"
+	"github.com/brave/go-translate/assets/update"
+)
+
+func TestManifest(t *testing.T) {
+	manifest, err := update.ReadManifest(".")
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Files, len(update.Sources))
+
+	for _, source := range update.Sources {
+		body, err := fs.ReadFile(assets.FS, source.Name)
+		assert.NoError(t, err, source.Name)
+		sum := sha256.Sum256(body)
+
+		file, ok := manifest.Files[source.Name]
+		assert.True(t, ok, source.Name)
+		assert.Equal(t, hex.EncodeToString(sum[:]), file.SHA256, source.Name)
+		assert.Equal(t, len(body), file.Size, source.Name)
+	}
+}
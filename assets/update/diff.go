@@ -0,0 +1,74 @@
+package update
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// printDiff prints the lines which differ between the current and the new
+// version of a file.
+func printDiff(out io.Writer, name string, current, next []byte) {
+	if current == nil {
+		_, _ = fmt.Fprintf(out, "%s: new file, %d bytes\n", name, len(next))
+		return
+	}
+	_, _ = fmt.Fprintf(out, "%s: changed, %d -> %d bytes\n", name, len(current), len(next))
+
+	lines := diffLines(splitLines(current), splitLines(next))
+	for i, line := range lines {
+		if i == maxDiffLines {
+			_, _ = fmt.Fprintf(out, "  ... %d more changed line(s)\n", len(lines)-maxDiffLines)
+			break
+		}
+		_, _ = fmt.Fprintf(out, "  %s%s\n", line[:1], truncate(line[1:], maxDiffLineLength))
+	}
+}
+
+// diffLines returns the lines removed from a, prefixed with -, and added in
+// b, prefixed with +, following the longest common subsequence of lines.
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	return lines
+}
+
+func splitLines(data []byte) []string {
+	return strings.Split(string(bytes.TrimSuffix(data, []byte("\n"))), "\n")
+}
+
+func truncate(line string, n int) string {
+	if len(line) <= n {
+		return line
+	}
+	return line[:n] + "..."
+}
@@ -0,0 +1,353 @@
+// Package update downloads new versions of the translate element scripts
+// served under /static/v1, checks that they are sane and installs them along
+// with a manifest of their SHA-256 hashes.
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brave/go-translate/assets"
+)
+
+// DefaultBaseURL is where the scripts are downloaded from by default.
+const DefaultBaseURL = "https://translate.googleapis.com/"
+
+// ManifestName is the name of the manifest, in the assets directory.
+const ManifestName = "manifest.json"
+
+// maxFileSize bounds the size of a downloaded file.
+const maxFileSize = 5 * 1024 * 1024 // 5MB
+
+// minFileSize is the size under which a downloaded file is considered truncated.
+const minFileSize = 1024
+
+// maxDiffLines bounds the number of changed lines printed per file.
+const maxDiffLines = 40
+
+// maxDiffLineLength bounds the length of a printed line, as minified
+// scripts have very long lines.
+const maxDiffLineLength = 120
+
+var (
+	javaScriptTypes = []string{"text/javascript", "application/javascript", "application/x-javascript"}
+	cssTypes        = []string{"text/css"}
+)
+
+// Source describes a file to download.
+type Source struct {
+	// Name is the path of the file in the assets directory.
+	Name string
+	// Path is the URL of the file, relative to the base URL.
+	Path string
+	// Header holds additional request headers.
+	Header http.Header
+	// ContentTypes are the acceptable media types of the file.
+	ContentTypes []string
+	// Markers must all be found in the file, along with the values of
+	// assets.ScriptRewrites. They are values the service and the browser
+	// depend on, missing ones mean the file changed too much to be used as is.
+	Markers []string
+}
+
+// Sources are the files served under /static/v1.
+var Sources = []Source{
+	{
+		Name:         "static/v1/element.js",
+		Path:         "translate_a/element.js?cb=cr.googleTranslate.onTranslateElementLoad&aus=true&clc=cr.googleTranslate.onLoadCSS&jlc=cr.googleTranslate.onLoadJavascript&hl=en",
+		Header:       http.Header{"Google-Translate-Element-Mode": {"library"}},
+		ContentTypes: javaScriptTypes,
+		Markers: []string{
+			"cr.googleTranslate.onTranslateElementLoad",
+			"cr.googleTranslate.onLoadCSS",
+			"cr.googleTranslate.onLoadJavascript",
+		},
+	},
+	{
+		Name:         "static/v1/js/element/main.js",
+		Path:         "_/translate_http/_/js/k=translate_http.tr.en_US.oOC1Oa7Rttc.O/am=Bg/d=1/exm=el_conf/ed=1/rs=AN8SPfqPYBV0hk02iWIVCgyiPCEnQfgUdA/m=el_main",
+		ContentTypes: javaScriptTypes,
+		Markers:      []string{"default_tr", "translate_a/t"},
+	},
+	{
+		Name:         "static/v1/css/translateelement.css",
+		Path:         "translate_static/css/translateelement.css",
+		ContentTypes: cssTypes,
+		Markers:      []string{".goog-te-"},
+	},
+}
+
+// Manifest records the files installed by the last update.
+type Manifest struct {
+	Files map[string]ManifestFile `json:"files"`
+}
+
+// ManifestFile describes an installed file.
+type ManifestFile struct {
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+	URL    string `json:"url"`
+}
+
+// Options configure an update.
+type Options struct {
+	// BaseURL is where the files are downloaded from.
+	BaseURL string
+	// Dir is the assets directory the files are installed into.
+	Dir string
+	// DryRun only prints the differences with the installed files.
+	DryRun bool
+	// Sources are the files to download, Sources if nil.
+	Sources []Source
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// Out receives the report of the update.
+	Out io.Writer
+}
+
+// downloaded is a file which was downloaded and checked.
+type downloaded struct {
+	source Source
+	url    string
+	body   []byte
+}
+
+// Main runs the update-assets command with its command line arguments.
+func Main(ctx context.Context, args []string, out io.Writer) error {
+	opts := Options{Out: out, Client: &http.Client{Timeout: time.Minute}}
+	flags := flag.NewFlagSet("update-assets", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.StringVar(&opts.BaseURL, "base-url", DefaultBaseURL, "URL the files are downloaded from")
+	flags.StringVar(&opts.Dir, "dir", "assets", "assets directory the files are installed into")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "only print the differences with the installed files")
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return Run(ctx, opts)
+}
+
+// Run downloads the files and checks them. The differences with the
+// installed files are printed, then all files and the manifest are installed
+// unless one of them failed the checks.
+func Run(ctx context.Context, opts Options) error {
+	sources := opts.Sources
+	if sources == nil {
+		sources = Sources
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base, err := url.Parse(opts.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %v", err)
+	}
+
+	files := make([]downloaded, 0, len(sources))
+	var errs []error
+	for _, source := range sources {
+		file, err := fetch(ctx, client, base, source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", source.Name, err))
+			continue
+		}
+		files = append(files, file)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("refusing to install: %v", errors.Join(errs...))
+	}
+
+	manifest := Manifest{Files: make(map[string]ManifestFile, len(files))}
+	changed := 0
+	for _, file := range files {
+		sum := sha256.Sum256(file.body)
+		manifest.Files[file.source.Name] = ManifestFile{
+			SHA256: hex.EncodeToString(sum[:]),
+			Size:   len(file.body),
+			URL:    file.url,
+		}
+
+		current, err := os.ReadFile(filepath.Join(opts.Dir, filepath.FromSlash(file.source.Name)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if bytes.Equal(current, file.body) {
+			_, _ = fmt.Fprintf(opts.Out, "%s: unchanged (sha256 %s)\n", file.source.Name, manifest.Files[file.source.Name].SHA256)
+			continue
+		}
+		changed++
+		printDiff(opts.Out, file.source.Name, current, file.body)
+	}
+
+	if opts.DryRun {
+		_, _ = fmt.Fprintf(opts.Out, "%d file(s) would change, dry run\n", changed)
+		return nil
+	}
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	install := map[string][]byte{ManifestName: data.Bytes()}
+	for _, file := range files {
+		install[file.source.Name] = file.body
+	}
+	if err := installFiles(opts.Dir, install); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(opts.Out, "%d file(s) changed, installed into %s\n", changed, opts.Dir)
+	return nil
+}
+
+// ReadManifest reads the manifest of the assets directory.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
+func fetch(ctx context.Context, client *http.Client, base *url.URL, source Source) (downloaded, error) {
+	ref, err := url.Parse(source.Path)
+	if err != nil {
+		return downloaded{}, err
+	}
+	u := base.ResolveReference(ref).String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return downloaded{}, err
+	}
+	for name, values := range source.Header {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return downloaded{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return downloaded{}, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, u)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize+1))
+	if err != nil {
+		return downloaded{}, err
+	}
+	if err := check(source, resp.Header.Get("Content-Type"), body); err != nil {
+		return downloaded{}, err
+	}
+	return downloaded{source: source, url: u, body: body}, nil
+}
+
+// check returns an error if the file doesn't look like the expected one, e.g.
+// an error page or a truncated download.
+func check(source Source, contentType string, body []byte) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !slices.Contains(source.ContentTypes, strings.ToLower(mediaType)) {
+		return fmt.Errorf("unexpected content type %q", contentType)
+	}
+	if len(body) > maxFileSize {
+		return fmt.Errorf("larger than %d bytes", maxFileSize)
+	}
+	if len(body) < minFileSize {
+		return fmt.Errorf("only %d bytes", len(body))
+	}
+	if trimmed := bytes.TrimSpace(body); bytes.HasPrefix(trimmed, []byte("<")) {
+		return errors.New("looks like an HTML page")
+	}
+	var missing []string
+	markers := slices.Clone(source.Markers)
+	for _, rewrite := range assets.ScriptRewrites[source.Name] {
+		markers = append(markers, rewrite.Old)
+	}
+	for _, marker := range markers {
+		if !bytes.Contains(body, []byte(marker)) {
+			missing = append(missing, fmt.Sprintf("%q", marker))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// installFiles writes files, by name, into dir. They are all written to a
+// staging directory first and only renamed into place once every one of them
+// was written, so that a failure, e.g. a full disk, leaves the installed
+// files alone rather than a mix of old and new ones.
+func installFiles(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(dir, ".update-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	names := slices.Sorted(maps.Keys(files))
+	for _, name := range names {
+		if err := writeFile(filepath.Join(staging, filepath.FromSlash(name)), files[name]); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(staging, filepath.FromSlash(name)), path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile replaces the file at path, so that it is never seen half written.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
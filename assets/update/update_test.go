@@ -0,0 +1,195 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// upstream is a stand-in for the server the files are downloaded from.
+type upstream struct {
+	files        map[string][]byte
+	contentTypes map[string]string
+	statuses     map[string]int
+	header       http.Header
+}
+
+func newUpstream(t *testing.T) *upstream {
+	u := &upstream{
+		files:        make(map[string][]byte),
+		contentTypes: make(map[string]string),
+		statuses:     make(map[string]int),
+	}
+	for _, source := range Sources {
+		body, err := os.ReadFile(filepath.Join("..", filepath.FromSlash(source.Name)))
+		assert.NoError(t, err)
+		path := "/" + strings.SplitN(source.Path, "?", 2)[0]
+		u.files[path] = body
+		u.contentTypes[path] = source.ContentTypes[0] + "; charset=UTF-8"
+	}
+	return u
+}
+
+func (u *upstream) start(t *testing.T) string {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/translate_a/element.js" {
+			u.header = r.Header.Clone()
+		}
+		body, ok := u.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", u.contentTypes[r.URL.Path])
+		if status, ok := u.statuses[r.URL.Path]; ok {
+			w.WriteHeader(status)
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL + "/"
+}
+
+func TestRun(t *testing.T) {
+	u := newUpstream(t)
+	element := u.files["/translate_a/element.js"]
+	u.files["/translate_a/element.js"] = append(bytes.Clone(element), []byte("\n// updated\n")...)
+	baseURL := u.start(t)
+
+	dir := t.TempDir()
+	assert.NoError(t, writeFile(filepath.Join(dir, "static", "v1", "element.js"), element))
+
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), Options{BaseURL: baseURL, Dir: dir, Out: &out}))
+	assert.Equal(t, "library", u.header.Get("Google-Translate-Element-Mode"))
+	assert.Contains(t, out.String(), "static/v1/element.js: changed, 77390 -> 77402 bytes\n  +// updated\n")
+	assert.Contains(t, out.String(), "static/v1/js/element/main.js: new file")
+	assert.Contains(t, out.String(), "3 file(s) changed")
+
+	manifest, err := ReadManifest(dir)
+	assert.NoError(t, err)
+	for _, source := range Sources {
+		body, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(source.Name)))
+		assert.NoError(t, err, source.Name)
+		sum := sha256.Sum256(body)
+		assert.Equal(t, ManifestFile{
+			SHA256: hex.EncodeToString(sum[:]),
+			Size:   len(body),
+			URL:    baseURL + source.Path,
+		}, manifest.Files[source.Name], source.Name)
+	}
+	installed, err := os.ReadFile(filepath.Join(dir, "static", "v1", "element.js"))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasSuffix(installed, []byte("\n// updated\n")))
+
+	// running again changes nothing
+	out.Reset()
+	assert.NoError(t, Run(context.Background(), Options{BaseURL: baseURL, Dir: dir, Out: &out}))
+	assert.Contains(t, out.String(), "static/v1/element.js: unchanged")
+	assert.Contains(t, out.String(), "0 file(s) changed")
+}
+
+func TestRunDryRun(t *testing.T) {
+	baseURL := newUpstream(t).start(t)
+	dir := t.TempDir()
+
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), Options{BaseURL: baseURL, Dir: dir, DryRun: true, Out: &out}))
+	assert.Contains(t, out.String(), "3 file(s) would change, dry run")
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRunRefuses(t *testing.T) {
+	for name, tc := range map[string]struct {
+		modify func(u *upstream)
+		err    string
+	}{
+		"not found": {
+			modify: func(u *upstream) { delete(u.files, "/translate_static/css/translateelement.css") },
+			err:    "unexpected status 404",
+		},
+		"error status": {
+			modify: func(u *upstream) { u.statuses["/translate_a/element.js"] = http.StatusServiceUnavailable },
+			err:    "unexpected status 503",
+		},
+		"content type": {
+			modify: func(u *upstream) { u.contentTypes["/translate_a/element.js"] = "text/plain" },
+			err:    `unexpected content type "text/plain"`,
+		},
+		"html page": {
+			modify: func(u *upstream) {
+				u.files["/translate_a/element.js"] = append([]byte("<!DOCTYPE html>\n"), u.files["/translate_a/element.js"]...)
+			},
+			err: "looks like an HTML page",
+		},
+		"truncated": {
+			modify: func(u *upstream) {
+				u.files["/translate_static/css/translateelement.css"] = []byte(".goog-te-banner{}")
+			},
+			err: "only 17 bytes",
+		},
+		"missing marker": {
+			modify: func(u *upstream) {
+				u.files["/translate_a/element.js"] = bytes.ReplaceAll(u.files["/translate_a/element.js"], []byte("c._pas=s;"), []byte("c._pas=t;"))
+			},
+			err: `static/v1/element.js: missing "c._pas=s;"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			u := newUpstream(t)
+			tc.modify(u)
+			baseURL := u.start(t)
+			dir := t.TempDir()
+
+			var out bytes.Buffer
+			err := Run(context.Background(), Options{BaseURL: baseURL, Dir: dir, Out: &out})
+			assert.ErrorContains(t, err, "refusing to install")
+			assert.ErrorContains(t, err, tc.err)
+
+			// none of the files are installed
+			entries, err := os.ReadDir(dir)
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestInstallFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, installFiles(dir, map[string][]byte{"a/b.js": []byte("old"), ManifestName: []byte("{}")}))
+
+	// "a/b.js/c" can't be written once "a/b.js" is a file
+	err := installFiles(dir, map[string][]byte{"a/b.js": []byte("new"), "a/b.js/c": []byte("new"), ManifestName: []byte("{}\n")})
+	assert.Error(t, err)
+	for name, want := range map[string]string{"a/b.js": "old", ManifestName: "{}"} {
+		body, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(body), name)
+	}
+
+	// the staging directory is removed
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestDiffLines(t *testing.T) {
+	assert.Empty(t, diffLines([]string{"a", "b"}, []string{"a", "b"}))
+	assert.Equal(t, []string{"-b", "+c", "+d"}, diffLines([]string{"a", "b", "e"}, []string{"a", "c", "d", "e"}))
+	assert.Equal(t, []string{"-a"}, diffLines([]string{"a", "b"}, []string{"b"}))
+
+	var out bytes.Buffer
+	printDiff(&out, "file.js", []byte(strings.Repeat("x", 200)+"\n"), []byte("y\n"))
+	assert.Equal(t, "file.js: changed, 201 -> 2 bytes\n  -"+strings.Repeat("x", maxDiffLineLength)+"...\n  +y\n", out.String())
+}
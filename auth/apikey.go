@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/sha256"
+)
+
+// APIKeys is an Authenticator accepting a fixed set of API keys.
+type APIKeys struct {
+	// clients by hash of their key, so that lookups don't depend on how much
+	// of a key matches
+	clients map[[sha256.Size]byte]string
+}
+
+// NewAPIKeys returns an authenticator accepting the keys, given by client name.
+func NewAPIKeys(keys map[string]string) *APIKeys {
+	a := &APIKeys{clients: make(map[[sha256.Size]byte]string, len(keys))}
+	for client, key := range keys {
+		a.clients[sha256.Sum256([]byte(key))] = client
+	}
+	return a
+}
+
+// Authenticate returns the client owning the key.
+func (a *APIKeys) Authenticate(token string) (Identity, error) {
+	if len(token) == 0 {
+		return Identity{}, ErrMissingToken
+	}
+	client, ok := a.clients[sha256.Sum256([]byte(token))]
+	if !ok {
+		return Identity{}, ErrInvalidToken
+	}
+	return Identity{Client: client, Method: MethodAPIKey}, nil
+}
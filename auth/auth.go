@@ -0,0 +1,62 @@
+// Package auth authenticates clients of the translate API by their bearer
+// token, either against static API keys or as HMAC-signed tokens, and carries
+// the resulting identity in request contexts.
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// Authentication methods, as reported by Identity.Method.
+const (
+	MethodNone   = "none"
+	MethodAPIKey = "api_key"
+	MethodHMAC   = "hmac"
+)
+
+var (
+	// ErrMissingToken is returned when a client sent no bearer token.
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrInvalidToken is returned for tokens which are unknown or malformed,
+	// or whose signature doesn't match.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpiredToken is returned for signed tokens past their expiry.
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Identity describes an authenticated client.
+type Identity struct {
+	// Client names the client, empty if clients are not authenticated.
+	Client string
+	// Method is how the client was authenticated.
+	Method string
+}
+
+// Authenticator tells clients apart by their bearer token.
+type Authenticator interface {
+	// Authenticate returns the identity of the client sending token, which is
+	// empty if none was sent.
+	Authenticate(token string) (Identity, error)
+}
+
+// AllowAll is an Authenticator letting every client through anonymously.
+type AllowAll struct{}
+
+// Authenticate returns an anonymous identity.
+func (AllowAll) Authenticate(string) (Identity, error) {
+	return Identity{Method: MethodNone}, nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the identity.
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity carried by ctx, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
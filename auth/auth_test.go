@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowAll(t *testing.T) {
+	id, err := AllowAll{}.Authenticate("")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{Method: MethodNone}, id)
+}
+
+func TestAPIKeys(t *testing.T) {
+	a := NewAPIKeys(map[string]string{"extension": "key-a", "ios": "key-b"})
+
+	id, err := a.Authenticate("key-b")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{Client: "ios", Method: MethodAPIKey}, id)
+
+	_, err = a.Authenticate("key-c")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = a.Authenticate("")
+	assert.ErrorIs(t, err, ErrMissingToken)
+}
+
+func TestHMAC(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a := NewHMAC([]byte("0123456789abcdef0123456789abcdef"))
+	a.now = func() time.Time { return now }
+
+	token := a.Sign("partner.example", now.Add(time.Hour))
+	id, err := a.Authenticate(token)
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{Client: "partner.example", Method: MethodHMAC}, id)
+
+	// tokens signed with another secret or altered are rejected
+	other := NewHMAC([]byte("fedcba9876543210fedcba9876543210"))
+	_, err = a.Authenticate(other.Sign("partner.example", now.Add(time.Hour)))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	payload, sig, _ := cutLast(token, ".")
+	_, err = a.Authenticate(strings.Replace(payload, "3", "4", 1) + "." + sig)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	for _, token := range []string{"garbage", "a.b.c", "a.b"} {
+		_, err = a.Authenticate(token)
+		assert.ErrorIs(t, err, ErrInvalidToken, token)
+	}
+	_, err = a.Authenticate("")
+	assert.ErrorIs(t, err, ErrMissingToken)
+
+	now = now.Add(time.Hour)
+	_, err = a.Authenticate(token)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := NewContext(context.Background(), Identity{Client: "ios", Method: MethodAPIKey})
+	id, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "ios", id.Client)
+}
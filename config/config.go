@@ -0,0 +1,860 @@
+// Package config provides the typed configuration of the translation service,
+// populated from an optional config file and environment variables.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets when a configuration is logged.
+const redacted = "[REDACTED]"
+
+// HTTP2Mode selects whether HTTP/2 is used for upstream connections.
+type HTTP2Mode string
+
+const (
+	// HTTP2Off only uses HTTP/1.1.
+	HTTP2Off HTTP2Mode = "off"
+	// HTTP2On negotiates HTTP/2 for https endpoints via ALPN.
+	HTTP2On HTTP2Mode = "on"
+	// HTTP2Cleartext additionally speaks HTTP/2 without TLS (h2c) to http endpoints.
+	HTTP2Cleartext HTTP2Mode = "h2c"
+)
+
+// Config is the configuration of the translation service.
+type Config struct {
+	// Address the API server listens on.
+	ListenAddr string `yaml:"listen_addr" json:"listen_addr"`
+	// Address the metrics server listens on.
+	MetricsAddr string `yaml:"metrics_addr" json:"metrics_addr"`
+	// Maximum time spent handling a request before the router responds with 504.
+	RouterTimeout time.Duration `yaml:"router_timeout" json:"router_timeout"`
+	// Maximum size of a response body read from Lingvanex.
+	MaxResponseSize int64 `yaml:"max_response_size" json:"max_response_size"`
+	// How often the config file is checked for changes, 0 disables the check.
+	ReloadInterval time.Duration `yaml:"reload_interval" json:"reload_interval"`
+	// How long to keep serving after SIGTERM while reporting not ready, so that
+	// load balancers stop sending new requests.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" json:"shutdown_delay"`
+	// How long requests in flight are waited for on shutdown.
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period" json:"shutdown_grace_period"`
+	// Directory holding static/v1, served instead of the files embedded in the
+	// binary when set, for development.
+	AssetsDir string `yaml:"assets_dir" json:"assets_dir"`
+	// Caching of the static files.
+	Static StaticConfig `yaml:"static" json:"static"`
+	// Configuration of TLS on the API server.
+	TLS TLSConfig `yaml:"tls" json:"tls"`
+	// Configuration of the Lingvanex upstream.
+	Lnx LnxConfig `yaml:"lnx" json:"lnx"`
+	// Configuration of the admin API.
+	Admin AdminConfig `yaml:"admin" json:"admin"`
+	// Configuration of OpenTelemetry tracing.
+	Tracing TracingConfig `yaml:"tracing" json:"tracing"`
+	// Configuration of per-client rate limiting.
+	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+	// Configuration of client authentication.
+	Auth AuthConfig `yaml:"auth" json:"auth"`
+	// Configuration of cross-origin requests to the API server.
+	CORS CORSConfig `yaml:"cors" json:"cors"`
+	// Size limits of translate requests.
+	Limits LimitsConfig `yaml:"limits" json:"limits"`
+}
+
+// LimitsConfig bounds the size of translate requests, which are rejected
+// before being parsed or sent to Lingvanex when exceeding a limit. Characters
+// are counted as Unicode code points. A limit of 0 disables it.
+type LimitsConfig struct {
+	// Maximum size of the request body in bytes.
+	MaxBodyBytes int64 `yaml:"max_body_bytes" json:"max_body_bytes"`
+	// Maximum number of segments to translate.
+	MaxSegments int `yaml:"max_segments" json:"max_segments"`
+	// Maximum number of characters of a single segment.
+	MaxSegmentChars int `yaml:"max_segment_chars" json:"max_segment_chars"`
+	// Maximum number of characters of all segments.
+	MaxTotalChars int `yaml:"max_total_chars" json:"max_total_chars"`
+}
+
+// StaticConfig is the configuration of HTTP caching of the static files.
+type StaticConfig struct {
+	// How long browsers may cache the static files.
+	MaxAge time.Duration `yaml:"max_age" json:"max_age"`
+	// Also serve the static files at URLs holding their content hash, e.g.
+	// element.<hash>.js, which may be cached forever.
+	HashedURLs bool `yaml:"hashed_urls" json:"hashed_urls"`
+	// Origins of this service, as scheme://host[:port], for which the
+	// translate element scripts are rewritten to load their resources and
+	// send translate requests to the origin they are requested from instead
+	// of Google. Scripts are served unchanged for other origins.
+	Origins []string `yaml:"origins" json:"origins"`
+}
+
+// scriptOriginPattern matches origins which are safe to write into scripts.
+var scriptOriginPattern = regexp.MustCompile(`^https?://([A-Za-z0-9.-]+|\[[0-9A-Fa-f:.]+\])(:[0-9]+)?$`)
+
+// TLSConfig is the configuration of TLS on the API server. HTTP/2 is
+// negotiated with clients when TLS is enabled.
+type TLSConfig struct {
+	// Path of the PEM certificate chain, re-read when it changes.
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	// Path of the PEM private key, re-read when it changes.
+	KeyFile string `yaml:"key_file" json:"key_file"`
+	// Serve a self-signed certificate for localhost generated at startup, for
+	// local development only.
+	SelfSigned bool `yaml:"self_signed" json:"self_signed"`
+}
+
+// Enabled reports whether the API server serves TLS.
+func (c *TLSConfig) Enabled() bool {
+	return len(c.CertFile) > 0 || c.SelfSigned
+}
+
+// CORSAnyOrigin allows cross-origin requests from any origin.
+const CORSAnyOrigin = "*"
+
+// CORSConfig is the cross-origin resource sharing policy of the API server.
+type CORSConfig struct {
+	// Origins allowed to send cross-origin requests, such as
+	// https://example.com or https://*.example.com for any subdomain.
+	// CORSAnyOrigin, the default, allows any origin and none disables
+	// cross-origin requests.
+	AllowedOrigins []string `yaml:"allowed_origins" json:"allowed_origins"`
+	// Methods allowed in cross-origin requests.
+	AllowedMethods []string `yaml:"allowed_methods" json:"allowed_methods"`
+	// Request headers allowed in cross-origin requests.
+	AllowedHeaders []string `yaml:"allowed_headers" json:"allowed_headers"`
+	// Response headers exposed to cross-origin requests.
+	ExposedHeaders []string `yaml:"exposed_headers" json:"exposed_headers"`
+	// How long browsers may cache the response to a preflight request.
+	MaxAge time.Duration `yaml:"max_age" json:"max_age"`
+}
+
+// AuthMode selects how clients of the translate API are authenticated.
+type AuthMode string
+
+const (
+	// AuthNone lets every client through.
+	AuthNone AuthMode = "none"
+	// AuthAPIKey requires one of the configured API keys as bearer token.
+	AuthAPIKey AuthMode = "api_key"
+	// AuthHMAC requires a bearer token signed with the HMAC secret.
+	AuthHMAC AuthMode = "hmac"
+)
+
+// minHMACSecretSize is the minimum size of the HMAC secret in bytes.
+const minHMACSecretSize = 32
+
+// AuthConfig is the configuration of client authentication on the translate
+// endpoint.
+type AuthConfig struct {
+	// How clients are authenticated.
+	Mode AuthMode `yaml:"mode" json:"mode"`
+	// API keys accepted in the api_key mode, by client name.
+	APIKeys map[string]Secret `yaml:"api_keys" json:"api_keys"`
+	// Secret the tokens accepted in the hmac mode are signed with.
+	HMACSecret Secret `yaml:"hmac_secret" json:"hmac_secret"`
+}
+
+// RateLimitKey selects how clients are told apart by the rate limiter.
+type RateLimitKey string
+
+const (
+	// RateLimitKeyIP limits each client IP address.
+	RateLimitKeyIP RateLimitKey = "ip"
+	// RateLimitKeyAPIKey limits each authenticated client. Clients which are
+	// not authenticated are limited by IP address.
+	RateLimitKeyAPIKey RateLimitKey = "api_key"
+)
+
+// RateLimitBackend selects where the rate limiter state is kept.
+type RateLimitBackend string
+
+const (
+	// RateLimitMemory keeps the state in memory, each instance limits separately.
+	RateLimitMemory RateLimitBackend = "memory"
+	// RateLimitRedis keeps the state in Redis, shared by all instances.
+	RateLimitRedis RateLimitBackend = "redis"
+)
+
+// RateLimitConfig is the configuration of per-client rate limiting of
+// translate requests. Clients may use up a whole window at once, the
+// allowance is then refilled steadily over the window.
+type RateLimitConfig struct {
+	// Requests allowed per client and window, 0 disables the limit.
+	Requests int `yaml:"requests" json:"requests"`
+	// Characters to translate allowed per client and window, 0 disables the limit.
+	Characters int `yaml:"characters" json:"characters"`
+	// Length of the window.
+	Window time.Duration `yaml:"window" json:"window"`
+	// How clients are told apart.
+	Key RateLimitKey `yaml:"key" json:"key"`
+	// Where the rate limiter state is kept.
+	Backend RateLimitBackend `yaml:"backend" json:"backend"`
+	// URL of the Redis server, e.g. redis://:password@host:6379/0.
+	RedisURL Secret `yaml:"redis_url" json:"redis_url"`
+}
+
+// Enabled reports whether any limit is configured.
+func (c *RateLimitConfig) Enabled() bool {
+	return c.Requests > 0 || c.Characters > 0
+}
+
+// TracingExporter selects where traces are exported to.
+type TracingExporter string
+
+const (
+	// TracingNone disables tracing.
+	TracingNone TracingExporter = "none"
+	// TracingStdout writes spans to stdout, for local debugging.
+	TracingStdout TracingExporter = "stdout"
+	// TracingOTLP exports spans to an OpenTelemetry collector over OTLP/HTTP.
+	TracingOTLP TracingExporter = "otlp"
+)
+
+// TracingConfig is the configuration of OpenTelemetry tracing.
+type TracingConfig struct {
+	// Where traces are exported to.
+	Exporter TracingExporter `yaml:"exporter" json:"exporter"`
+	// URL of the OTLP/HTTP collector, defaults to the standard OTEL_EXPORTER_OTLP_* variables.
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+	// Fraction of new traces which are sampled, between 0 and 1.
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio"`
+}
+
+// AdminConfig is the configuration of the admin API, which is served on its
+// own address so that it can be kept off the public network.
+type AdminConfig struct {
+	// Address the admin server listens on, the admin API is disabled if empty.
+	Addr string `yaml:"addr" json:"addr"`
+	// The bearer token required by the admin API.
+	Token Secret `yaml:"token" json:"token"`
+	// A file holding the bearer token required by the admin API.
+	TokenFile string `yaml:"token_file" json:"token_file"`
+}
+
+// LnxConfig is the configuration of the Lingvanex upstream.
+// Endpoints are either described in detail by Endpoints or listed by Hosts and
+// Weights, but not both.
+type LnxConfig struct {
+	// A list of endpoint URLs.
+	Hosts []string `yaml:"hosts" json:"hosts"`
+	// A list of default endpoint weights, matching Hosts by index.
+	Weights []float64 `yaml:"weights" json:"weights"`
+	// A list of endpoint descriptions.
+	Endpoints []EndpointConfig `yaml:"endpoints" json:"endpoints"`
+	// The API key sent to endpoints which don't have their own.
+	APIKey Secret `yaml:"api_key" json:"api_key"`
+	// A file holding the API key sent to endpoints which don't have their own.
+	APIKeyFile string `yaml:"api_key_file" json:"api_key_file"`
+	// Deadline of a single translate round-trip.
+	UpstreamTimeout time.Duration `yaml:"upstream_timeout" json:"upstream_timeout"`
+	// Connection pool and timeouts of the upstream transport.
+	Transport TransportConfig `yaml:"transport" json:"transport"`
+	// Whether upstream latency and response metrics are labeled by language pair.
+	MetricsPairLabels bool `yaml:"metrics_pair_labels" json:"metrics_pair_labels"`
+	// Maximum number of requests in flight per endpoint, 0 means no limit.
+	MaxConcurrency int `yaml:"max_concurrency" json:"max_concurrency"`
+	// Maximum number of requests waiting for an endpoint at its concurrency limit.
+	QueueSize int `yaml:"queue_size" json:"queue_size"`
+	// How long a request waits in the queue before it is rejected.
+	QueueTimeout time.Duration `yaml:"queue_timeout" json:"queue_timeout"`
+}
+
+// TransportConfig describes the connection pool and timeouts of the transport
+// used for each Lingvanex endpoint.
+type TransportConfig struct {
+	// Maximum number of idle connections kept in the pool.
+	MaxIdleConns int `yaml:"max_idle_conns" json:"max_idle_conns"`
+	// Maximum number of idle connections kept per endpoint host.
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host" json:"max_idle_conns_per_host"`
+	// Maximum number of connections per endpoint host, 0 means no limit.
+	MaxConnsPerHost int `yaml:"max_conns_per_host" json:"max_conns_per_host"`
+	// How long an idle connection stays in the pool.
+	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout" json:"idle_conn_timeout"`
+	// Timeout for establishing a TCP connection.
+	DialTimeout time.Duration `yaml:"dial_timeout" json:"dial_timeout"`
+	// TCP keep-alive period of upstream connections.
+	KeepAlive time.Duration `yaml:"keep_alive" json:"keep_alive"`
+	// Timeout for the TLS handshake.
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout" json:"tls_handshake_timeout"`
+	// Timeout for receiving the response headers once the request was written.
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout" json:"response_header_timeout"`
+	// Overall timeout of an upstream request, including reading the body.
+	RequestTimeout time.Duration `yaml:"request_timeout" json:"request_timeout"`
+	// Whether to use HTTP/2 for upstream connections.
+	HTTP2 HTTP2Mode `yaml:"http2" json:"http2"`
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() Config {
+	return Config{
+		ListenAddr:          ":8195",
+		MetricsAddr:         ":9090",
+		RouterTimeout:       60 * time.Second,
+		MaxResponseSize:     5 * 1024 * 1024, // 5MB
+		ReloadInterval:      10 * time.Second,
+		ShutdownGracePeriod: 30 * time.Second,
+		Static: StaticConfig{
+			MaxAge: 24 * time.Hour,
+		},
+		Lnx: LnxConfig{
+			UpstreamTimeout: 30 * time.Second,
+			Transport:       DefaultTransportConfig(),
+			QueueSize:       16,
+			QueueTimeout:    100 * time.Millisecond,
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingNone,
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Window:  time.Minute,
+			Key:     RateLimitKeyIP,
+			Backend: RateLimitMemory,
+		},
+		Auth: AuthConfig{
+			Mode: AuthNone,
+		},
+		Limits: LimitsConfig{
+			MaxBodyBytes:    1024 * 1024, // 1MB
+			MaxSegments:     1024,
+			MaxSegmentChars: 10000,
+			MaxTotalChars:   100000,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{CORSAnyOrigin},
+			AllowedMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-Id"},
+			ExposedHeaders: []string{"Retry-After", "X-Request-Id"},
+			MaxAge:         10 * time.Minute,
+		},
+	}
+}
+
+// DefaultTransportConfig returns the transport configuration used when nothing
+// is overridden.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		MaxConnsPerHost:       0,
+		IdleConnTimeout:       90 * time.Second,
+		DialTimeout:           5 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 0,
+		RequestTimeout:        60 * time.Second,
+		HTTP2:                 HTTP2Off,
+	}
+}
+
+// FilePath returns the path of the config file, or an empty string if none is used.
+func FilePath() string {
+	return os.Getenv("CONFIG_FILE")
+}
+
+// Load returns the default configuration, overridden by the config file named
+// in CONFIG_FILE if set, overridden in turn by environment variables. The
+// result is validated.
+func Load() (*Config, error) {
+	conf := Default()
+
+	if path := FilePath(); len(path) > 0 {
+		err := conf.loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := conf.loadEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	err = conf.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// loadFile overrides the configuration with the values present in a YAML or
+// JSON file.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides the configuration with the environment variables that are set.
+func (c *Config) loadEnv() error {
+	var errs []error
+
+	envString("LISTEN_ADDR", &c.ListenAddr)
+	envString("METRICS_ADDR", &c.MetricsAddr)
+	errs = append(errs,
+		envDuration("ROUTER_TIMEOUT", &c.RouterTimeout),
+		envInt64("MAX_RESPONSE_SIZE", &c.MaxResponseSize),
+		envDuration("CONFIG_RELOAD_INTERVAL", &c.ReloadInterval),
+		envDuration("SHUTDOWN_DELAY", &c.ShutdownDelay),
+		envDuration("SHUTDOWN_GRACE_PERIOD", &c.ShutdownGracePeriod),
+	)
+
+	if val := os.Getenv("LNX_HOST"); len(val) > 0 {
+		c.Lnx.Hosts = strings.Split(val, ",")
+	}
+	if val := os.Getenv("LNX_WEIGHTS"); len(val) > 0 {
+		c.Lnx.Weights = nil
+		for _, weight := range strings.Split(val, ",") {
+			if len(weight) == 0 {
+				continue
+			}
+			w, err := strconv.ParseFloat(weight, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for LNX_WEIGHTS: %q", val))
+				break
+			}
+			c.Lnx.Weights = append(c.Lnx.Weights, w)
+		}
+	}
+	if val := os.Getenv("LNX_API_KEY"); len(val) > 0 {
+		c.Lnx.APIKey = Secret(val)
+	}
+	envString("LNX_API_KEY_FILE", &c.Lnx.APIKeyFile)
+	errs = append(errs,
+		envDuration("LNX_UPSTREAM_TIMEOUT", &c.Lnx.UpstreamTimeout),
+		envBool("LNX_METRICS_PAIR_LABELS", &c.Lnx.MetricsPairLabels),
+		envInt("LNX_MAX_CONCURRENCY", &c.Lnx.MaxConcurrency),
+		envInt("LNX_QUEUE_SIZE", &c.Lnx.QueueSize),
+		envDuration("LNX_QUEUE_TIMEOUT", &c.Lnx.QueueTimeout),
+	)
+
+	t := &c.Lnx.Transport
+	errs = append(errs,
+		envInt("LNX_MAX_IDLE_CONNS", &t.MaxIdleConns),
+		envInt("LNX_MAX_IDLE_CONNS_PER_HOST", &t.MaxIdleConnsPerHost),
+		envInt("LNX_MAX_CONNS_PER_HOST", &t.MaxConnsPerHost),
+		envDuration("LNX_IDLE_CONN_TIMEOUT", &t.IdleConnTimeout),
+		envDuration("LNX_DIAL_TIMEOUT", &t.DialTimeout),
+		envDuration("LNX_KEEP_ALIVE", &t.KeepAlive),
+		envDuration("LNX_TLS_HANDSHAKE_TIMEOUT", &t.TLSHandshakeTimeout),
+		envDuration("LNX_RESPONSE_HEADER_TIMEOUT", &t.ResponseHeaderTimeout),
+		envDuration("LNX_REQUEST_TIMEOUT", &t.RequestTimeout),
+	)
+	if val := os.Getenv("LNX_HTTP2"); len(val) > 0 {
+		t.HTTP2 = HTTP2Mode(val)
+	}
+
+	if val := os.Getenv("TRACING_EXPORTER"); len(val) > 0 {
+		c.Tracing.Exporter = TracingExporter(val)
+	}
+	envString("TRACING_ENDPOINT", &c.Tracing.Endpoint)
+	errs = append(errs, envFloat("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio))
+
+	envString("ASSETS_DIR", &c.AssetsDir)
+	errs = append(errs,
+		envDuration("STATIC_MAX_AGE", &c.Static.MaxAge),
+		envBool("STATIC_HASHED_URLS", &c.Static.HashedURLs),
+	)
+	envList("STATIC_ORIGINS", &c.Static.Origins)
+
+	envString("TLS_CERT_FILE", &c.TLS.CertFile)
+	envString("TLS_KEY_FILE", &c.TLS.KeyFile)
+	errs = append(errs, envBool("TLS_SELF_SIGNED", &c.TLS.SelfSigned))
+
+	rl := &c.RateLimit
+	errs = append(errs,
+		envInt("RATE_LIMIT_REQUESTS", &rl.Requests),
+		envInt("RATE_LIMIT_CHARACTERS", &rl.Characters),
+		envDuration("RATE_LIMIT_WINDOW", &rl.Window),
+	)
+	if val := os.Getenv("RATE_LIMIT_KEY"); len(val) > 0 {
+		rl.Key = RateLimitKey(val)
+	}
+	if val := os.Getenv("RATE_LIMIT_BACKEND"); len(val) > 0 {
+		rl.Backend = RateLimitBackend(val)
+	}
+	if val := os.Getenv("RATE_LIMIT_REDIS_URL"); len(val) > 0 {
+		rl.RedisURL = Secret(val)
+	}
+
+	if val := os.Getenv("AUTH_MODE"); len(val) > 0 {
+		c.Auth.Mode = AuthMode(val)
+	}
+	if val := os.Getenv("AUTH_API_KEYS"); len(val) > 0 {
+		c.Auth.APIKeys = make(map[string]Secret)
+		for _, pair := range strings.Split(val, ",") {
+			client, key, ok := strings.Cut(pair, ":")
+			if !ok {
+				// the value holds keys, don't print it
+				errs = append(errs, errors.New("invalid value for AUTH_API_KEYS: expected client:key pairs"))
+				break
+			}
+			c.Auth.APIKeys[client] = Secret(key)
+		}
+	}
+	if val := os.Getenv("AUTH_HMAC_SECRET"); len(val) > 0 {
+		c.Auth.HMACSecret = Secret(val)
+	}
+
+	errs = append(errs,
+		envInt64("MAX_REQUEST_BODY_BYTES", &c.Limits.MaxBodyBytes),
+		envInt("MAX_SEGMENTS", &c.Limits.MaxSegments),
+		envInt("MAX_SEGMENT_CHARS", &c.Limits.MaxSegmentChars),
+		envInt("MAX_TOTAL_CHARS", &c.Limits.MaxTotalChars),
+	)
+
+	envList("CORS_ALLOWED_ORIGINS", &c.CORS.AllowedOrigins)
+	envList("CORS_ALLOWED_METHODS", &c.CORS.AllowedMethods)
+	envList("CORS_ALLOWED_HEADERS", &c.CORS.AllowedHeaders)
+	envList("CORS_EXPOSED_HEADERS", &c.CORS.ExposedHeaders)
+	errs = append(errs, envDuration("CORS_MAX_AGE", &c.CORS.MaxAge))
+
+	envString("ADMIN_ADDR", &c.Admin.Addr)
+	if val := os.Getenv("ADMIN_TOKEN"); len(val) > 0 {
+		c.Admin.Token = Secret(val)
+	}
+	envString("ADMIN_TOKEN_FILE", &c.Admin.TokenFile)
+
+	return errors.Join(errs...)
+}
+
+// Validate checks that the configuration is complete and consistent.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if len(c.ListenAddr) == 0 {
+		errs = append(errs, errors.New("listen_addr must not be empty"))
+	}
+	if len(c.MetricsAddr) == 0 {
+		errs = append(errs, errors.New("metrics_addr must not be empty"))
+	}
+	if c.RouterTimeout <= 0 {
+		errs = append(errs, errors.New("router_timeout must be positive"))
+	}
+	if c.MaxResponseSize <= 0 {
+		errs = append(errs, errors.New("max_response_size must be positive"))
+	}
+	if c.ReloadInterval < 0 {
+		errs = append(errs, errors.New("reload_interval must not be negative"))
+	}
+	if c.ShutdownDelay < 0 || c.ShutdownGracePeriod < 0 {
+		errs = append(errs, errors.New("shutdown_delay and shutdown_grace_period must not be negative"))
+	}
+	if len(c.AssetsDir) > 0 {
+		if info, err := os.Stat(c.AssetsDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("assets_dir %q is not a directory", c.AssetsDir))
+		}
+	}
+	if c.Static.MaxAge < 0 {
+		errs = append(errs, errors.New("static.max_age must not be negative"))
+	}
+	for _, origin := range c.Static.Origins {
+		if !scriptOriginPattern.MatchString(origin) {
+			errs = append(errs, fmt.Errorf("invalid origin %q in static.origins, expected scheme://host[:port]", origin))
+		}
+	}
+	errs = append(errs, c.TLS.validate())
+	errs = append(errs, c.Lnx.validate(c.RouterTimeout))
+	errs = append(errs, c.Admin.validate())
+	errs = append(errs, c.Tracing.validate())
+	errs = append(errs, c.RateLimit.validate())
+	errs = append(errs, c.Auth.validate())
+	errs = append(errs, c.CORS.validate())
+	if c.Limits.MaxBodyBytes < 0 || c.Limits.MaxSegments < 0 || c.Limits.MaxSegmentChars < 0 || c.Limits.MaxTotalChars < 0 {
+		errs = append(errs, errors.New("limits must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *TLSConfig) validate() error {
+	if (len(c.CertFile) == 0) != (len(c.KeyFile) == 0) {
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+	if c.SelfSigned && len(c.CertFile) > 0 {
+		return errors.New("tls.self_signed and tls.cert_file are mutually exclusive")
+	}
+	return nil
+}
+
+func (c *RateLimitConfig) validate() error {
+	var errs []error
+
+	if c.Requests < 0 || c.Characters < 0 {
+		errs = append(errs, errors.New("rate_limit.requests and rate_limit.characters must not be negative"))
+	}
+	if c.Window <= 0 {
+		errs = append(errs, errors.New("rate_limit.window must be positive"))
+	}
+	switch c.Key {
+	case RateLimitKeyIP, RateLimitKeyAPIKey:
+	default:
+		errs = append(errs, fmt.Errorf("invalid rate_limit.key %q", c.Key))
+	}
+	switch c.Backend {
+	case RateLimitMemory:
+	case RateLimitRedis:
+		if len(c.RedisURL) == 0 {
+			errs = append(errs, errors.New("rate_limit.redis_url is required with the redis backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid rate_limit.backend %q", c.Backend))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *AuthConfig) validate() error {
+	switch c.Mode {
+	case AuthNone:
+	case AuthAPIKey:
+		if len(c.APIKeys) == 0 {
+			return errors.New("auth.api_keys is required with the api_key mode")
+		}
+		for client, key := range c.APIKeys {
+			if len(client) == 0 || len(key) == 0 {
+				return errors.New("auth.api_keys must not hold empty client names or keys")
+			}
+		}
+	case AuthHMAC:
+		if len(c.HMACSecret) < minHMACSecretSize {
+			return fmt.Errorf("auth.hmac_secret must be at least %d bytes", minHMACSecretSize)
+		}
+	default:
+		return fmt.Errorf("invalid auth.mode %q", c.Mode)
+	}
+	return nil
+}
+
+func (c *CORSConfig) validate() error {
+	var errs []error
+
+	for _, origin := range c.AllowedOrigins {
+		if origin == CORSAnyOrigin {
+			if len(c.AllowedOrigins) > 1 {
+				errs = append(errs, fmt.Errorf("cors.allowed_origins must not list other origins with %q", CORSAnyOrigin))
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 ||
+			len(u.Path) > 0 || len(u.RawQuery) > 0 || len(u.Fragment) > 0 || u.User != nil {
+			errs = append(errs, fmt.Errorf("invalid origin %q in cors.allowed_origins, expected scheme://host[:port]", origin))
+		}
+	}
+	if len(c.AllowedMethods) == 0 {
+		errs = append(errs, errors.New("cors.allowed_methods must not be empty"))
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *TracingConfig) validate() error {
+	var errs []error
+
+	switch c.Exporter {
+	case TracingNone, TracingStdout, TracingOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("invalid tracing.exporter %q", c.Exporter))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *AdminConfig) validate() error {
+	if len(c.Addr) == 0 {
+		return nil
+	}
+	if len(c.Token) == 0 && len(c.TokenFile) == 0 {
+		return errors.New("admin.token or admin.token_file is required when admin.addr is set")
+	}
+	if len(c.TokenFile) > 0 && len(c.Token) > 0 {
+		return errors.New("admin: token and token_file are mutually exclusive")
+	}
+	if len(c.TokenFile) > 0 {
+		if _, err := readSecretFile(c.TokenFile); err != nil {
+			return fmt.Errorf("admin: invalid token_file: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *LnxConfig) validate(routerTimeout time.Duration) error {
+	var errs []error
+
+	switch {
+	case len(c.Hosts) == 0 && len(c.Endpoints) == 0:
+		errs = append(errs, errors.New("must pass at least one endpoint via LNX_HOST, lnx.hosts or lnx.endpoints"))
+	case len(c.Hosts) > 0 && len(c.Endpoints) > 0:
+		errs = append(errs, errors.New("lnx.hosts (LNX_HOST) and lnx.endpoints are mutually exclusive"))
+	case len(c.Hosts) > 0:
+		// a single endpoint does not need a weight
+		if len(c.Weights) != len(c.Hosts) && !(len(c.Hosts) == 1 && len(c.Weights) == 0) {
+			errs = append(errs, errors.New("number of endpoints must match number of weights"))
+		}
+	case len(c.Weights) > 0:
+		errs = append(errs, errors.New("lnx.weights (LNX_WEIGHTS) requires lnx.hosts (LNX_HOST)"))
+	}
+	errs = append(errs, validateCredential("lnx", c.APIKey, c.APIKeyFile))
+	errs = append(errs, validateEndpoints(c.EndpointConfigs()))
+	if c.UpstreamTimeout <= 0 {
+		errs = append(errs, errors.New("lnx.upstream_timeout must be positive"))
+	} else if c.UpstreamTimeout > routerTimeout {
+		errs = append(errs, errors.New("lnx.upstream_timeout must not exceed router_timeout"))
+	}
+	if c.MaxConcurrency < 0 || c.QueueSize < 0 || c.QueueTimeout < 0 {
+		errs = append(errs, errors.New("lnx.max_concurrency, lnx.queue_size and lnx.queue_timeout must not be negative"))
+	}
+	errs = append(errs, c.Transport.validate())
+
+	return errors.Join(errs...)
+}
+
+func (c *TransportConfig) validate() error {
+	var errs []error
+
+	if c.MaxIdleConns < 0 || c.MaxIdleConnsPerHost < 0 || c.MaxConnsPerHost < 0 {
+		errs = append(errs, errors.New("lnx.transport connection limits must not be negative"))
+	}
+	if c.IdleConnTimeout < 0 || c.DialTimeout < 0 || c.KeepAlive < 0 || c.TLSHandshakeTimeout < 0 ||
+		c.ResponseHeaderTimeout < 0 || c.RequestTimeout < 0 {
+		errs = append(errs, errors.New("lnx.transport timeouts must not be negative"))
+	}
+	switch c.HTTP2 {
+	case HTTP2Off, HTTP2On, HTTP2Cleartext:
+	default:
+		errs = append(errs, fmt.Errorf("invalid lnx.transport.http2 mode %q", c.HTTP2))
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the configuration with secrets replaced, suitable
+// for logging.
+func (c Config) Redacted() Config {
+	if len(c.Lnx.APIKey) > 0 {
+		c.Lnx.APIKey = redacted
+	}
+	if len(c.Admin.Token) > 0 {
+		c.Admin.Token = redacted
+	}
+	if len(c.RateLimit.RedisURL) > 0 {
+		c.RateLimit.RedisURL = redacted
+	}
+	if len(c.Auth.APIKeys) > 0 {
+		keys := make(map[string]Secret, len(c.Auth.APIKeys))
+		for client := range c.Auth.APIKeys {
+			keys[client] = redacted
+		}
+		c.Auth.APIKeys = keys
+	}
+	if len(c.Auth.HMACSecret) > 0 {
+		c.Auth.HMACSecret = redacted
+	}
+	endpoints := make([]EndpointConfig, len(c.Lnx.Endpoints))
+	for i, endpoint := range c.Lnx.Endpoints {
+		if len(endpoint.APIKey) > 0 {
+			endpoint.APIKey = redacted
+		}
+		endpoints[i] = endpoint
+	}
+	c.Lnx.Endpoints = endpoints
+	return c
+}
+
+func envString(name string, field *string) {
+	if val := os.Getenv(name); len(val) > 0 {
+		*field = val
+	}
+}
+
+// envList sets field to the comma separated values of the variable, without
+// surrounding whitespace.
+func envList(name string, field *[]string) {
+	if val := os.Getenv(name); len(val) > 0 {
+		*field = nil
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				*field = append(*field, item)
+			}
+		}
+	}
+}
+
+func envInt(name string, field *int) error {
+	if val := os.Getenv(name); len(val) > 0 {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q", name, val)
+		}
+		*field = n
+	}
+	return nil
+}
+
+func envInt64(name string, field *int64) error {
+	if val := os.Getenv(name); len(val) > 0 {
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q", name, val)
+		}
+		*field = n
+	}
+	return nil
+}
+
+func envFloat(name string, field *float64) error {
+	if val := os.Getenv(name); len(val) > 0 {
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q", name, val)
+		}
+		*field = f
+	}
+	return nil
+}
+
+func envBool(name string, field *bool) error {
+	if val := os.Getenv(name); len(val) > 0 {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q", name, val)
+		}
+		*field = b
+	}
+	return nil
+}
+
+func envDuration(name string, field *time.Duration) error {
+	if val := os.Getenv(name); len(val) > 0 {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q", name, val)
+		}
+		*field = d
+	}
+	return nil
+}
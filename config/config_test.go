@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("LNX_HOST", "http://lnx-a:8080/api,http://lnx-b:8080/api")
+	t.Setenv("LNX_WEIGHTS", "0.25,0.75")
+	t.Setenv("LNX_API_KEY", "secret")
+	t.Setenv("LISTEN_ADDR", ":8080")
+	t.Setenv("LNX_IDLE_CONN_TIMEOUT", "15s")
+	t.Setenv("LNX_HTTP2", "h2c")
+	t.Setenv("TLS_SELF_SIGNED", "true")
+	t.Setenv("AUTH_MODE", "api_key")
+	t.Setenv("AUTH_API_KEYS", "ios:key-a,extension:key-b")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example.com, https://*.b.example.com")
+
+	conf, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://lnx-a:8080/api", "http://lnx-b:8080/api"}, conf.Lnx.Hosts)
+	assert.Equal(t, []float64{0.25, 0.75}, conf.Lnx.Weights)
+	assert.Equal(t, ":8080", conf.ListenAddr)
+	assert.Equal(t, Default().MetricsAddr, conf.MetricsAddr)
+	assert.Equal(t, 15*time.Second, conf.Lnx.Transport.IdleConnTimeout)
+	assert.Equal(t, HTTP2Cleartext, conf.Lnx.Transport.HTTP2)
+	assert.Equal(t, Default().Lnx.Transport.MaxIdleConns, conf.Lnx.Transport.MaxIdleConns)
+	assert.True(t, conf.TLS.Enabled())
+	assert.Equal(t, AuthAPIKey, conf.Auth.Mode)
+	assert.Equal(t, map[string]Secret{"ios": "key-a", "extension": "key-b"}, conf.Auth.APIKeys)
+	assert.Equal(t, []string{"https://a.example.com", "https://*.b.example.com"}, conf.CORS.AllowedOrigins)
+	assert.Equal(t, Default().CORS.AllowedMethods, conf.CORS.AllowedMethods)
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", `
+metrics_addr: ":9191"
+router_timeout: 20s
+lnx:
+  hosts: ["http://lnx-a:8080/api"]
+  upstream_timeout: 10s
+cors:
+  allowed_origins: []
+`))
+		t.Setenv("LNX_UPSTREAM_TIMEOUT", "5s")
+
+		conf, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, ":9191", conf.MetricsAddr)
+		assert.Equal(t, 20*time.Second, conf.RouterTimeout)
+		// environment variables take precedence over the file
+		assert.Equal(t, 5*time.Second, conf.Lnx.UpstreamTimeout)
+		// cross-origin requests, allowed from any origin by default, are disabled
+		assert.Equal(t, []string{CORSAnyOrigin}, Default().CORS.AllowedOrigins)
+		assert.Empty(t, conf.CORS.AllowedOrigins)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", writeFile(t, "config.json", `{"lnx": {"hosts": ["https://lnx-a/api"], "weights": [1]}}`))
+
+		conf, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"https://lnx-a/api"}, conf.Lnx.Hosts)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", "lnx:\n  hostz: []\n"))
+
+		_, err := Load()
+		assert.Error(t, err)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	conf := Default()
+	assert.ErrorContains(t, conf.Validate(), "at least one endpoint")
+
+	conf.Lnx.Hosts = []string{"lnx-a:8080"}
+	assert.ErrorContains(t, conf.Validate(), "invalid endpoint URL")
+
+	conf.Lnx.Hosts = []string{"http://lnx-a:8080/api", "http://lnx-b:8080/api"}
+	conf.Lnx.Weights = []float64{1}
+	assert.ErrorContains(t, conf.Validate(), "number of endpoints must match")
+
+	conf.Lnx.Weights = []float64{1, 1}
+	conf.TLS.CertFile = "cert.pem"
+	assert.ErrorContains(t, conf.Validate(), "tls.cert_file and tls.key_file")
+
+	conf.TLS.KeyFile = "key.pem"
+	conf.TLS.SelfSigned = true
+	assert.ErrorContains(t, conf.Validate(), "tls.self_signed")
+
+	conf.TLS.SelfSigned = false
+	conf.Lnx.UpstreamTimeout = 2 * conf.RouterTimeout
+	assert.ErrorContains(t, conf.Validate(), "must not exceed router_timeout")
+
+	conf.Lnx.UpstreamTimeout = time.Second
+	conf.ShutdownGracePeriod = -time.Second
+	assert.ErrorContains(t, conf.Validate(), "shutdown_grace_period")
+
+	conf.ShutdownGracePeriod = time.Second
+	conf.Limits.MaxSegments = -1
+	assert.ErrorContains(t, conf.Validate(), "limits")
+
+	conf.Limits.MaxSegments = 0
+	conf.AssetsDir = filepath.Join(t.TempDir(), "missing")
+	assert.ErrorContains(t, conf.Validate(), "assets_dir")
+
+	conf.AssetsDir = t.TempDir()
+	conf.Static.Origins = []string{"https://translate.example.com'+alert(1)+'"}
+	assert.ErrorContains(t, conf.Validate(), "static.origins")
+
+	conf.Static.Origins = []string{"https://translate.example.com", "http://127.0.0.1:8195", "http://[::1]:8195"}
+	conf.Lnx.Transport.HTTP2 = "maybe"
+	assert.ErrorContains(t, conf.Validate(), "http2")
+
+	conf.Lnx.Transport.HTTP2 = HTTP2On
+	conf.Lnx.MaxConcurrency = -1
+	assert.ErrorContains(t, conf.Validate(), "max_concurrency")
+
+	conf.Lnx.MaxConcurrency = 32
+	conf.Tracing.Exporter = "jaeger"
+	assert.ErrorContains(t, conf.Validate(), "tracing.exporter")
+
+	conf.Tracing.Exporter = TracingOTLP
+	conf.Tracing.SampleRatio = 1.5
+	assert.ErrorContains(t, conf.Validate(), "tracing.sample_ratio")
+
+	conf.Tracing.SampleRatio = 0.1
+	conf.RateLimit.Requests = 100
+	conf.RateLimit.Backend = RateLimitRedis
+	assert.ErrorContains(t, conf.Validate(), "rate_limit.redis_url")
+
+	conf.RateLimit.RedisURL = "redis://localhost:6379/0"
+	conf.RateLimit.Key = "cookie"
+	assert.ErrorContains(t, conf.Validate(), "rate_limit.key")
+
+	conf.RateLimit.Key = RateLimitKeyAPIKey
+	conf.Auth.Mode = AuthAPIKey
+	assert.ErrorContains(t, conf.Validate(), "auth.api_keys")
+
+	conf.Auth.Mode = AuthHMAC
+	conf.Auth.HMACSecret = "short"
+	assert.ErrorContains(t, conf.Validate(), "auth.hmac_secret")
+
+	conf.Auth.HMACSecret = "0123456789abcdef0123456789abcdef"
+	conf.CORS.AllowedOrigins = []string{"https://a.example.com/path"}
+	assert.ErrorContains(t, conf.Validate(), "cors.allowed_origins")
+
+	conf.CORS.AllowedOrigins = []string{CORSAnyOrigin, "https://a.example.com"}
+	assert.ErrorContains(t, conf.Validate(), "cors.allowed_origins")
+
+	conf.CORS.AllowedOrigins = []string{"https://a.example.com", "https://*.b.example.com:8443"}
+	assert.NoError(t, conf.Validate())
+}
+
+func TestRedacted(t *testing.T) {
+	conf := Default()
+	conf.Lnx.APIKey = "secret"
+	conf.Auth.APIKeys = map[string]Secret{"ios": "key-a"}
+
+	assert.Equal(t, Secret("[REDACTED]"), conf.Redacted().Lnx.APIKey)
+	assert.Equal(t, Secret("secret"), conf.Lnx.APIKey)
+	assert.Equal(t, map[string]Secret{"ios": "[REDACTED]"}, conf.Redacted().Auth.APIKeys)
+	assert.Equal(t, Secret("key-a"), conf.Auth.APIKeys["ios"])
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/brave/go-translate/language"
+)
+
+// wildcard matches any language in a language pair.
+const wildcard = "*"
+
+// EndpointConfig describes a Lingvanex endpoint.
+// Language pairs are written as "<source>:<target>" using Google language
+// codes, either of which may be "*" to match any language, e.g. "en:es",
+// "en:*" or "*:de".
+type EndpointConfig struct {
+	// The endpoint URL.
+	URL string `yaml:"url" json:"url"`
+	// The API key sent to the endpoint, defaults to lnx.api_key.
+	APIKey Secret `yaml:"api_key" json:"api_key"`
+	// A file holding the API key sent to the endpoint, defaults to lnx.api_key_file.
+	APIKeyFile string `yaml:"api_key_file" json:"api_key_file"`
+	// The default weight of the endpoint, defaults to 1.
+	Weight *float64 `yaml:"weight" json:"weight"`
+	// Weights overriding the default weight for specific language pairs.
+	PairWeights map[string]float64 `yaml:"pair_weights" json:"pair_weights"`
+	// If not empty, only these language pairs are routed to the endpoint.
+	Allow []string `yaml:"allow" json:"allow"`
+	// Language pairs which are never routed to the endpoint.
+	Block []string `yaml:"block" json:"block"`
+	// Free-form labels describing the endpoint, e.g. its region.
+	Tags []string `yaml:"tags" json:"tags"`
+	// Maximum number of requests in flight to the endpoint, defaults to
+	// lnx.max_concurrency. 0 means no limit.
+	MaxConcurrency *int `yaml:"max_concurrency" json:"max_concurrency"`
+}
+
+// EndpointConfigs returns the endpoint descriptions, converting Hosts and
+// Weights when Endpoints is not used.
+func (c *LnxConfig) EndpointConfigs() []EndpointConfig {
+	if len(c.Endpoints) > 0 || len(c.Hosts) == 0 {
+		return c.Endpoints
+	}
+
+	endpoints := make([]EndpointConfig, len(c.Hosts))
+	for i, host := range c.Hosts {
+		endpoints[i].URL = host
+		if i < len(c.Weights) {
+			endpoints[i].Weight = &c.Weights[i]
+		}
+	}
+	return endpoints
+}
+
+// DefaultWeight returns the weight of the endpoint for language pairs without
+// an override.
+func (e *EndpointConfig) DefaultWeight() float64 {
+	if e.Weight == nil {
+		return 1
+	}
+	return *e.Weight
+}
+
+// Allows reports whether the language pair may be routed to the endpoint.
+func (e *EndpointConfig) Allows(from, to string) bool {
+	for _, pair := range e.Block {
+		if matchPair(pair, from, to) {
+			return false
+		}
+	}
+	if len(e.Allow) == 0 {
+		return true
+	}
+	for _, pair := range e.Allow {
+		if matchPair(pair, from, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// PairWeight returns the weight of the endpoint for the language pair. An
+// exact pair override takes precedence over a source wildcard override
+// ("en:*"), which takes precedence over a target wildcard override ("*:es").
+func (e *EndpointConfig) PairWeight(from, to string) float64 {
+	for _, pair := range []string{from + ":" + to, from + ":" + wildcard, wildcard + ":" + to} {
+		if weight, ok := e.PairWeights[pair]; ok {
+			return weight
+		}
+	}
+	return e.DefaultWeight()
+}
+
+// ParsePair splits a language pair into its source and target language.
+func ParsePair(pair string) (string, string, error) {
+	from, to, ok := strings.Cut(pair, ":")
+	if !ok || len(from) == 0 || len(to) == 0 {
+		return "", "", fmt.Errorf("invalid language pair %q, expected <source>:<target>", pair)
+	}
+	for _, lang := range []string{from, to} {
+		if lang != wildcard && !slices.Contains(language.ChromiumLanguageList, lang) {
+			return "", "", fmt.Errorf("invalid language pair %q, unknown language %q", pair, lang)
+		}
+	}
+	return from, to, nil
+}
+
+func matchPair(pair, from, to string) bool {
+	pairFrom, pairTo, err := ParsePair(pair)
+	if err != nil {
+		return false
+	}
+	return (pairFrom == wildcard || pairFrom == from) && (pairTo == wildcard || pairTo == to)
+}
+
+func validateEndpoints(endpoints []EndpointConfig) error {
+	var errs []error
+
+	seen := make(map[string]bool, len(endpoints))
+	for i, endpoint := range endpoints {
+		prefix := fmt.Sprintf("lnx.endpoints[%d]", i)
+
+		u, err := url.Parse(endpoint.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid endpoint URL %q", prefix, endpoint.URL))
+		}
+		if seen[endpoint.URL] {
+			errs = append(errs, fmt.Errorf("%s: duplicate endpoint URL %q", prefix, endpoint.URL))
+		}
+		seen[endpoint.URL] = true
+
+		errs = append(errs, validateCredential(prefix, endpoint.APIKey, endpoint.APIKeyFile))
+
+		if endpoint.DefaultWeight() < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid endpoint weight %v", prefix, endpoint.DefaultWeight()))
+		}
+		for pair, weight := range endpoint.PairWeights {
+			if _, _, err := ParsePair(pair); err != nil {
+				errs = append(errs, fmt.Errorf("%s.pair_weights: %v", prefix, err))
+			}
+			if weight < 0 {
+				errs = append(errs, fmt.Errorf("%s.pair_weights: invalid weight %v for %q", prefix, weight, pair))
+			}
+		}
+		for _, pair := range append(slices.Clone(endpoint.Allow), endpoint.Block...) {
+			if _, _, err := ParsePair(pair); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", prefix, err))
+			}
+		}
+		if endpoint.MaxConcurrency != nil && *endpoint.MaxConcurrency < 0 {
+			errs = append(errs, fmt.Errorf("%s: max_concurrency must not be negative", prefix))
+		}
+		for _, tag := range endpoint.Tags {
+			if len(strings.TrimSpace(tag)) == 0 {
+				errs = append(errs, fmt.Errorf("%s: tags must not be empty", prefix))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateCredential(prefix string, value Secret, path string) error {
+	if len(path) == 0 {
+		return nil
+	}
+	if len(value) > 0 {
+		return fmt.Errorf("%s: api_key and api_key_file are mutually exclusive", prefix)
+	}
+	if _, err := readSecretFile(path); err != nil {
+		return fmt.Errorf("%s: invalid api_key_file: %v", prefix, err)
+	}
+	return nil
+}
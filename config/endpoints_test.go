@@ -0,0 +1,85 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointConfig(t *testing.T) {
+	weight := 0.5
+	endpoint := EndpointConfig{
+		URL:         "http://lnx-a:8080/api",
+		Weight:      &weight,
+		PairWeights: map[string]float64{"en:es": 3, "en:*": 2, "*:es": 1},
+		Block:       []string{"*:ja"},
+	}
+
+	assert.Equal(t, 3.0, endpoint.PairWeight("en", "es"))
+	assert.Equal(t, 2.0, endpoint.PairWeight("en", "de"))
+	assert.Equal(t, 1.0, endpoint.PairWeight("de", "es"))
+	assert.Equal(t, 0.5, endpoint.PairWeight("de", "fr"))
+
+	assert.True(t, endpoint.Allows("en", "es"))
+	assert.False(t, endpoint.Allows("en", "ja"))
+
+	endpoint.Allow = []string{"en:*"}
+	assert.True(t, endpoint.Allows("en", "es"))
+	assert.False(t, endpoint.Allows("de", "es"))
+	assert.False(t, endpoint.Allows("en", "ja"))
+
+	assert.Equal(t, 1.0, (&EndpointConfig{}).DefaultWeight())
+}
+
+func TestLoadEndpointsFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", `
+lnx:
+  api_key: shared
+  endpoints:
+    - url: http://lnx-a:8080/api
+      weight: 2
+      pair_weights:
+        "en:zh-CN": 5
+      block: ["*:ja"]
+      tags: [us-west]
+    - url: http://lnx-b:8080/api
+      api_key: other
+      allow: ["en:*", "*:en"]
+`))
+
+	conf, err := Load()
+	assert.NoError(t, err)
+	endpoints := conf.Lnx.EndpointConfigs()
+	assert.Len(t, endpoints, 2)
+	assert.Equal(t, 2.0, endpoints[0].DefaultWeight())
+	assert.Equal(t, 5.0, endpoints[0].PairWeight("en", "zh-CN"))
+	assert.Equal(t, []string{"us-west"}, endpoints[0].Tags)
+	assert.Equal(t, Secret("other"), endpoints[1].APIKey)
+	assert.Equal(t, Secret("[REDACTED]"), conf.Redacted().Lnx.Endpoints[1].APIKey)
+	assert.Equal(t, Secret("other"), conf.Lnx.Endpoints[1].APIKey)
+}
+
+func TestValidateEndpoints(t *testing.T) {
+	negative := -1.0
+	conf := Default()
+	conf.Lnx.Endpoints = []EndpointConfig{
+		{URL: "http://lnx-a:8080/api", PairWeights: map[string]float64{"en-es": 1}},
+		{URL: "http://lnx-a:8080/api", Weight: &negative},
+		{URL: "http://lnx-b:8080/api", Allow: []string{"en:klingon"}, Tags: []string{" "}},
+	}
+
+	err := conf.Validate()
+	assert.ErrorContains(t, err, `lnx.endpoints[0].pair_weights: invalid language pair "en-es"`)
+	assert.ErrorContains(t, err, `lnx.endpoints[1]: duplicate endpoint URL`)
+	assert.ErrorContains(t, err, `lnx.endpoints[1]: invalid endpoint weight -1`)
+	assert.ErrorContains(t, err, `lnx.endpoints[2]: invalid language pair "en:klingon", unknown language "klingon"`)
+	assert.ErrorContains(t, err, `lnx.endpoints[2]: tags must not be empty`)
+
+	conf.Lnx.Endpoints = conf.Lnx.Endpoints[:1]
+	conf.Lnx.Endpoints[0].PairWeights = nil
+	conf.Lnx.Hosts = []string{"http://lnx-b:8080/api"}
+	assert.ErrorContains(t, conf.Validate(), "mutually exclusive")
+
+	conf.Lnx.Hosts = nil
+	assert.NoError(t, conf.Validate())
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secret is a string which is redacted whenever it is printed or marshalled,
+// so that it can't leak into logs or error messages.
+type Secret string
+
+// String returns a redacted placeholder instead of the secret.
+func (s Secret) String() string {
+	if len(s) == 0 {
+		return ""
+	}
+	return redacted
+}
+
+// GoString returns a redacted placeholder instead of the secret.
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// MarshalJSON marshals a redacted placeholder instead of the secret.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Credential is an API key given either as a value or as a path to a file
+// holding it, as Kubernetes mounts secrets. Files are re-read when they
+// change, so keys can be rotated without a restart.
+type Credential struct {
+	value Secret
+	path  string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// NewCredential returns a credential for the given value, or for the content
+// of the file at path if path is not empty.
+func NewCredential(value Secret, path string) *Credential {
+	return &Credential{value: value, path: path}
+}
+
+// Get returns the current API key. If the key file can't be read after it was
+// read successfully once, the last key read is returned.
+func (c *Credential) Get() (Secret, error) {
+	if len(c.path) == 0 {
+		return c.value, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(c.path)
+	if err == nil && info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return c.value, nil
+	}
+	if err == nil {
+		var value Secret
+		value, err = readSecretFile(c.path)
+		if err == nil {
+			c.value, c.modTime, c.size = value, info.ModTime(), info.Size()
+			return c.value, nil
+		}
+	}
+	if c.modTime.IsZero() {
+		return "", fmt.Errorf("error reading API key file: %v", err)
+	}
+	return c.value, nil
+}
+
+// readSecretFile returns the content of a secret file without surrounding whitespace.
+func readSecretFile(path string) (Secret, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(data))
+	if len(value) == 0 {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return Secret(value), nil
+}
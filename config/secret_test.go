@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	endpoint := EndpointConfig{URL: "http://lnx-a:8080/api", APIKey: "hunter2"}
+
+	for _, formatted := range []string{
+		fmt.Sprint(endpoint.APIKey),
+		fmt.Sprintf("%v", endpoint),
+		fmt.Sprintf("%+v", endpoint),
+		fmt.Sprintf("%#v", endpoint),
+	} {
+		assert.NotContains(t, formatted, "hunter2")
+	}
+
+	data, err := json.Marshal(endpoint)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+	assert.Contains(t, string(data), `"api_key":"[REDACTED]"`)
+
+	assert.Equal(t, "", Secret("").String())
+}
+
+func TestCredential(t *testing.T) {
+	key, err := NewCredential("value", "").Get()
+	assert.NoError(t, err)
+	assert.Equal(t, Secret("value"), key)
+
+	path := writeFile(t, "api-key", "first\n")
+	cred := NewCredential("", path)
+	key, err = cred.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, Secret("first"), key)
+
+	// rotate the key, making sure the modification time changes
+	assert.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	key, err = cred.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, Secret("second"), key)
+
+	// the last key is kept while the file is missing
+	assert.NoError(t, os.Remove(path))
+	key, err = cred.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, Secret("second"), key)
+
+	_, err = NewCredential("", path).Get()
+	assert.Error(t, err)
+}
+
+func TestValidateCredential(t *testing.T) {
+	conf := Default()
+	conf.Lnx.Hosts = []string{"http://lnx-a:8080/api"}
+	conf.Lnx.APIKeyFile = writeFile(t, "api-key", "  \n")
+	assert.ErrorContains(t, conf.Validate(), "is empty")
+
+	conf.Lnx.APIKey = "value"
+	assert.ErrorContains(t, conf.Validate(), "mutually exclusive")
+}
@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/brave-intl/bat-go/libs/logging"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brave/go-translate/config"
+)
+
+var errUnknownEndpoint = errors.New("unknown endpoint")
+
+// AdminEndpoint describes an endpoint and its runtime state in admin API responses.
+type AdminEndpoint struct {
+	URL            string             `json:"url"`
+	Tags           []string           `json:"tags"`
+	Drained        bool               `json:"drained"`
+	InFlight       int64              `json:"in_flight"`
+	Health         EndpointHealth     `json:"health"`
+	DefaultWeight  float64            `json:"default_weight"`
+	PairWeights    map[string]float64 `json:"pair_weights,omitempty"`
+	Allow          []string           `json:"allow,omitempty"`
+	Block          []string           `json:"block,omitempty"`
+	SupportedPairs int                `json:"supported_pairs"`
+}
+
+// adminWeightRequest is the body of a weight update. The default weight of
+// the endpoint is changed if Pair is empty, otherwise the weight of the pair.
+type adminWeightRequest struct {
+	URL    string   `json:"url"`
+	Pair   string   `json:"pair"`
+	Weight *float64 `json:"weight"`
+}
+
+// adminEndpointRequest is the body of requests acting on a single endpoint.
+type adminEndpointRequest struct {
+	URL string `json:"url"`
+}
+
+// AdminRouter returns the router of the admin API, which lets operators
+// inspect the endpoints and change their weights, or drain and re-enable
+// them, at runtime. Changes are lost when the endpoint configuration is
+// reloaded, except for drained endpoints which stay drained.
+func AdminRouter(conf *config.AdminConfig) chi.Router {
+	r := chi.NewRouter()
+	r.Use(adminAuth(config.NewCredential(conf.Token, conf.TokenFile)))
+
+	r.Get("/admin/health", GetHealthDetails)
+	r.Get("/admin/endpoints", ListEndpoints)
+	r.Get("/admin/endpoints/pairs", GetEndpointPairs)
+	r.Post("/admin/endpoints/weights", SetEndpointWeight)
+	r.Post("/admin/endpoints/drain", DrainEndpoint)
+	r.Post("/admin/endpoints/enable", EnableEndpoint)
+	return r
+}
+
+// adminAuth only lets requests through which carry the admin bearer token.
+func adminAuth(cred *config.Credential) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := cred.Get()
+			if err != nil {
+				writeError(w, r, ErrUnavailable, "admin API unavailable", err)
+				return
+			}
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || len(token) == 0 || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				writeError(w, r, ErrUnauthorized, http.StatusText(http.StatusUnauthorized), nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ListEndpoints writes the endpoints with their weights, supported language
+// pairs and runtime state.
+func ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	conf := CurrentLnxEndpoint()
+
+	endpoints := make([]AdminEndpoint, 0, len(conf.Endpoints))
+	for i, endpoint := range conf.Endpoints {
+		endpointConf := conf.EndpointConfigs[endpoint]
+		state := stateOf(endpoint)
+		endpoints = append(endpoints, AdminEndpoint{
+			URL:            endpoint,
+			Tags:           endpointConf.Tags,
+			Drained:        state.drained.Load(),
+			InFlight:       state.inFlight.Load(),
+			Health:         state.health(),
+			DefaultWeight:  conf.DefaultWeights[i],
+			PairWeights:    endpointConf.PairWeights,
+			Allow:          endpointConf.Allow,
+			Block:          endpointConf.Block,
+			SupportedPairs: len(conf.pairWeights(endpoint)),
+		})
+	}
+	writeAdminJSON(w, r, endpoints)
+}
+
+// GetEndpointPairs writes the weight of the endpoint given by the url query
+// parameter for each of the language pairs it serves.
+func GetEndpointPairs(w http.ResponseWriter, r *http.Request) {
+	conf := CurrentLnxEndpoint()
+	endpoint := r.URL.Query().Get("url")
+	if _, ok := conf.EndpointConfigs[endpoint]; !ok {
+		writeError(w, r, ErrNotFound, fmt.Sprintf("%v: %q", errUnknownEndpoint, endpoint), nil)
+		return
+	}
+	writeAdminJSON(w, r, conf.pairWeights(endpoint))
+}
+
+// SetEndpointWeight changes the default weight of an endpoint or its weight
+// for a language pair.
+func SetEndpointWeight(w http.ResponseWriter, r *http.Request) {
+	var req adminWeightRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleBadRequestError(w, r, "error parsing request body", err)
+		return
+	}
+	if req.Weight == nil || *req.Weight < 0 {
+		handleBadRequestError(w, r, "invalid weight", errors.New("weight must be a non-negative number"))
+		return
+	}
+	if len(req.Pair) > 0 {
+		if _, _, err := config.ParsePair(req.Pair); err != nil {
+			handleBadRequestError(w, r, "invalid pair", err)
+			return
+		}
+	}
+
+	err := updateLnxEndpoint(func(conf *LnxEndpointConfiguration) (*LnxEndpointConfiguration, error) {
+		return conf.withWeight(req.URL, req.Pair, *req.Weight)
+	})
+	if err != nil {
+		handleAdminEndpointError(w, r, req.URL, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info().
+		Str("endpoint", req.URL).
+		Str("pair", req.Pair).
+		Float64("weight", *req.Weight).
+		Msg("Endpoint weight changed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DrainEndpoint stops routing new requests to an endpoint, requests in flight
+// are allowed to finish.
+func DrainEndpoint(w http.ResponseWriter, r *http.Request) {
+	setDrained(w, r, true)
+}
+
+// EnableEndpoint resumes routing requests to a drained endpoint.
+func EnableEndpoint(w http.ResponseWriter, r *http.Request) {
+	setDrained(w, r, false)
+}
+
+func setDrained(w http.ResponseWriter, r *http.Request, drained bool) {
+	var req adminEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleBadRequestError(w, r, "error parsing request body", err)
+		return
+	}
+	if _, ok := CurrentLnxEndpoint().EndpointConfigs[req.URL]; !ok {
+		handleAdminEndpointError(w, r, req.URL, errUnknownEndpoint)
+		return
+	}
+
+	stateOf(req.URL).drained.Store(drained)
+	logging.FromContext(r.Context()).Info().
+		Str("endpoint", req.URL).
+		Bool("drained", drained).
+		Msg("Endpoint drain state changed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleAdminEndpointError(w http.ResponseWriter, r *http.Request, endpoint string, err error) {
+	if errors.Is(err, errUnknownEndpoint) {
+		writeError(w, r, ErrNotFound, fmt.Sprintf("%v: %q", err, endpoint), nil)
+		return
+	}
+	handleInternalServerError(w, r, "error updating endpoint", err)
+}
+
+func writeAdminJSON(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		handleInternalServerError(w, r, "error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, err = w.Write(body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error().Err(err).Msg("Error writing response body for admin requests")
+	}
+}
+
+// pairWeights returns the weight of the endpoint for each language pair it
+// serves, keyed by "<source>:<target>".
+func (c *LnxEndpointConfiguration) pairWeights(endpoint string) map[string]float64 {
+	pairs := make(map[string]float64)
+	for sl, tls := range c.LanguagePairWeights {
+		for tl, weights := range tls {
+			if weight, ok := weights[endpoint]; ok {
+				pairs[sl+":"+tl] = weight
+			}
+		}
+	}
+	return pairs
+}
+
+// withWeight returns a copy of the configuration where the default weight of
+// the endpoint, or its weight for the pair if not empty, is changed. Weights
+// of more specific pairs still take precedence, as in the configuration file.
+func (c *LnxEndpointConfiguration) withWeight(endpoint, pair string, weight float64) (*LnxEndpointConfiguration, error) {
+	i := slices.Index(c.Endpoints, endpoint)
+	if i < 0 {
+		return nil, errUnknownEndpoint
+	}
+
+	endpointConf := c.EndpointConfigs[endpoint]
+	if len(pair) == 0 {
+		endpointConf.Weight = &weight
+	} else {
+		endpointConf.PairWeights = maps.Clone(endpointConf.PairWeights)
+		if endpointConf.PairWeights == nil {
+			endpointConf.PairWeights = make(map[string]float64)
+		}
+		endpointConf.PairWeights[pair] = weight
+	}
+
+	next := *c
+	next.EndpointConfigs = maps.Clone(c.EndpointConfigs)
+	next.EndpointConfigs[endpoint] = endpointConf
+	next.DefaultWeights = slices.Clone(c.DefaultWeights)
+	next.DefaultWeights[i] = endpointConf.DefaultWeight()
+	next.LanguagePairWeights = make(map[string]map[string]map[string]float64, len(c.LanguagePairWeights))
+	for sl, tls := range c.LanguagePairWeights {
+		next.LanguagePairWeights[sl] = make(map[string]map[string]float64, len(tls))
+		for tl, weights := range tls {
+			if _, ok := weights[endpoint]; ok {
+				weights = maps.Clone(weights)
+				weights[endpoint] = endpointConf.PairWeight(sl, tl)
+			}
+			next.LanguagePairWeights[sl][tl] = weights
+		}
+	}
+	return &next, nil
+}
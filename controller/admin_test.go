@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/config"
+	"github.com/brave/go-translate/language"
+)
+
+func setupAdminTest(t *testing.T) (http.Handler, []string) {
+	list := language.GoogleLanguageList{
+		Sl: map[string]string{"en": "English", "es": "Spanish", "de": "Deutsch"},
+		Tl: map[string]string{"en": "English", "es": "Spanish", "de": "Deutsch"},
+	}
+	endpoints := []string{"http://admin-test-1/api", "http://admin-test-2/api"}
+	conf, err := NewLnxEndpointConfigurationFromConfig([]config.EndpointConfig{
+		{URL: endpoints[0], Tags: []string{"us-west"}},
+		{URL: endpoints[1], Block: []string{"*:de"}},
+	}, []language.GoogleLanguageList{list, list})
+	assert.NoError(t, err)
+	SetLnxEndpoint(conf)
+
+	t.Cleanup(func() {
+		for _, endpoint := range endpoints {
+			endpointStates.Delete(endpoint)
+		}
+	})
+	return AdminRouter(&config.AdminConfig{Token: "admin-token"}), endpoints
+}
+
+func adminRequest(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer admin-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestAdminAuth(t *testing.T) {
+	router, _ := setupAdminTest(t)
+
+	for _, header := range []string{"", "Bearer wrong", "admin-token"} {
+		r := httptest.NewRequest(http.MethodGet, "/admin/endpoints", nil)
+		r.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	assert.Equal(t, http.StatusOK, adminRequest(router, http.MethodGet, "/admin/endpoints", "").Code)
+}
+
+func TestAdminListEndpoints(t *testing.T) {
+	router, endpoints := setupAdminTest(t)
+	stateOf(endpoints[1]).recordFailure("503 Service Unavailable")
+
+	w := adminRequest(router, http.MethodGet, "/admin/endpoints", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var list []AdminEndpoint
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list, 2)
+	assert.Equal(t, endpoints[0], list[0].URL)
+	assert.Equal(t, []string{"us-west"}, list[0].Tags)
+	assert.Equal(t, healthUnknown, list[0].Health.Status)
+	// including the 3 or 2 targets of auto-detected source languages
+	assert.Equal(t, 12, list[0].SupportedPairs)
+	assert.Equal(t, 8, list[1].SupportedPairs)
+	assert.Equal(t, healthDegraded, list[1].Health.Status)
+	assert.Equal(t, "503 Service Unavailable", list[1].Health.LastError)
+
+	w = adminRequest(router, http.MethodGet, "/admin/endpoints/pairs?url="+endpoints[1], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var pairs map[string]float64
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &pairs))
+	assert.Equal(t, 1.0, pairs["en:es"])
+	assert.NotContains(t, pairs, "en:de")
+
+	w = adminRequest(router, http.MethodGet, "/admin/endpoints/pairs?url=http://unknown", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminSetEndpointWeight(t *testing.T) {
+	router, endpoints := setupAdminTest(t)
+	before := CurrentLnxEndpoint()
+
+	w := adminRequest(router, http.MethodPost, "/admin/endpoints/weights", `{"url": "`+endpoints[0]+`", "weight": 3}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = adminRequest(router, http.MethodPost, "/admin/endpoints/weights", `{"url": "`+endpoints[1]+`", "pair": "en:es", "weight": 0}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	conf := CurrentLnxEndpoint()
+	assert.Equal(t, []float64{3, 1}, conf.DefaultWeights)
+	assert.Equal(t, map[string]float64{endpoints[0]: 3, endpoints[1]: 0}, conf.LanguagePairWeights["en"]["es"])
+	assert.Equal(t, map[string]float64{endpoints[0]: 3, endpoints[1]: 1}, conf.LanguagePairWeights["es"]["en"])
+	assert.Equal(t, map[string]float64{endpoints[0]: 3}, conf.LanguagePairWeights["en"]["de"])
+	assert.Equal(t, endpoints[0], conf.GetEndpoint("en", "es"))
+	// the previous configuration is left untouched for requests in flight
+	assert.Equal(t, []float64{1, 1}, before.DefaultWeights)
+	assert.Equal(t, map[string]float64{endpoints[0]: 1, endpoints[1]: 1}, before.LanguagePairWeights["en"]["es"])
+
+	for _, body := range []string{
+		`{"url": "` + endpoints[0] + `", "weight": -1}`,
+		`{"url": "` + endpoints[0] + `"}`,
+		`{"url": "` + endpoints[0] + `", "pair": "en-es", "weight": 1}`,
+		`not json`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, adminRequest(router, http.MethodPost, "/admin/endpoints/weights", body).Code)
+	}
+	w = adminRequest(router, http.MethodPost, "/admin/endpoints/weights", `{"url": "http://unknown", "weight": 1}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminDrainEndpoint(t *testing.T) {
+	router, endpoints := setupAdminTest(t)
+
+	w := adminRequest(router, http.MethodPost, "/admin/endpoints/drain", `{"url": "`+endpoints[0]+`"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	conf := CurrentLnxEndpoint()
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, endpoints[1], conf.GetEndpoint("en", "es"))
+	}
+	// the only endpoint serving the pair is drained
+	assert.Equal(t, "", conf.GetEndpoint("en", "de"))
+	assert.True(t, conf.supportsPair("en", "de"))
+
+	w = adminRequest(router, http.MethodPost, "/admin/endpoints/enable", `{"url": "`+endpoints[0]+`"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, endpoints[0], conf.GetEndpoint("en", "de"))
+
+	w = adminRequest(router, http.MethodPost, "/admin/endpoints/drain", `{"url": "http://unknown"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
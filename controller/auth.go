@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/brave-intl/bat-go/libs/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/brave/go-translate/auth"
+	"github.com/brave/go-translate/config"
+)
+
+// clientField is the log field holding the authenticated client.
+const clientField = "client"
+
+var (
+	authFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_auth_failures_total",
+		Help: "The total number of translate requests rejected for lack of valid credentials, by reason",
+	},
+		[]string{"reason"},
+	)
+	clientRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_client_requests_total",
+		Help: "The total number of authenticated translate requests, by client",
+	},
+		[]string{"client"},
+	)
+)
+
+// NewAuthenticator returns the authenticator for the configuration.
+func NewAuthenticator(conf *config.AuthConfig) auth.Authenticator {
+	switch conf.Mode {
+	case config.AuthAPIKey:
+		keys := make(map[string]string, len(conf.APIKeys))
+		for client, key := range conf.APIKeys {
+			keys[client] = string(key)
+		}
+		return auth.NewAPIKeys(keys)
+	case config.AuthHMAC:
+		return auth.NewHMAC([]byte(conf.HMACSecret))
+	}
+	return auth.AllowAll{}
+}
+
+// Authenticate rejects requests without valid credentials with 401
+// Unauthorized. The identity of the client is attached to the request context
+// and added to every log line of the request.
+func Authenticate(a auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			var id auth.Identity
+			var err error
+			if !ok && len(header) > 0 {
+				err = fmt.Errorf("%w: unsupported authorization scheme", auth.ErrInvalidToken)
+			} else {
+				id, err = a.Authenticate(token)
+			}
+			if err != nil {
+				authFailures.With(prometheus.Labels{"reason": authFailureReason(err)}).Inc()
+				w.Header().Set("WWW-Authenticate", `Bearer realm="translate"`)
+				writeError(w, r, ErrUnauthorized, http.StatusText(http.StatusUnauthorized), err)
+				return
+			}
+
+			ctx := auth.NewContext(r.Context(), id)
+			if len(id.Client) > 0 {
+				clientRequests.With(prometheus.Labels{"client": id.Client}).Inc()
+				logger := logging.FromContext(ctx).With().Str(clientField, id.Client).Logger()
+				ctx = logger.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authFailureReason returns the metric label of an authentication error.
+func authFailureReason(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrMissingToken):
+		return "missing_token"
+	case errors.Is(err, auth.ErrExpiredToken):
+		return "expired_token"
+	}
+	return "invalid_token"
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/auth"
+	"github.com/brave/go-translate/config"
+)
+
+func serveAuthenticated(t *testing.T, conf config.AuthConfig, token string) (*httptest.ResponseRecorder, auth.Identity) {
+	var id auth.Identity
+	handler := Authenticate(NewAuthenticator(&conf))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		id, ok = auth.FromContext(r.Context())
+		assert.True(t, ok)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := newTranslateRequest("sl=en&tl=es", "Hello")
+	if len(token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, id
+}
+
+func TestAuthenticateAllowAll(t *testing.T) {
+	w, id := serveAuthenticated(t, config.AuthConfig{Mode: config.AuthNone}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, auth.Identity{Method: auth.MethodNone}, id)
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	conf := config.AuthConfig{Mode: config.AuthAPIKey, APIKeys: map[string]config.Secret{"ios": "key-a"}}
+
+	before := testutil.ToFloat64(clientRequests.WithLabelValues("ios"))
+	w, id := serveAuthenticated(t, conf, "key-a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, auth.Identity{Client: "ios", Method: auth.MethodAPIKey}, id)
+	assert.Equal(t, before+1, testutil.ToFloat64(clientRequests.WithLabelValues("ios")))
+
+	for token, reason := range map[string]string{"key-b": "invalid_token", "": "missing_token"} {
+		before := testutil.ToFloat64(authFailures.WithLabelValues(reason))
+		w, _ = serveAuthenticated(t, conf, token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="translate"`, w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, ErrUnauthorized, decodeError(t, w).Code)
+		assert.Equal(t, before+1, testutil.ToFloat64(authFailures.WithLabelValues(reason)))
+	}
+}
+
+func TestAuthenticateScheme(t *testing.T) {
+	conf := config.AuthConfig{Mode: config.AuthAPIKey, APIKeys: map[string]config.Secret{"ios": "key-a"}}
+	handler := Authenticate(NewAuthenticator(&conf))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, header := range []string{"key-a", "Basic key-a", "bearer key-a"} {
+		before := testutil.ToFloat64(authFailures.WithLabelValues("invalid_token"))
+		r := newTranslateRequest("sl=en&tl=es", "Hello")
+		r.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, header)
+		assert.Equal(t, ErrUnauthorized, decodeError(t, w).Code)
+		assert.Equal(t, before+1, testutil.ToFloat64(authFailures.WithLabelValues("invalid_token")))
+	}
+}
+
+func TestAuthenticateHMAC(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	conf := config.AuthConfig{Mode: config.AuthHMAC, HMACSecret: config.Secret(secret)}
+	signer := auth.NewHMAC([]byte(secret))
+
+	w, id := serveAuthenticated(t, conf, signer.Sign("partner", time.Now().Add(time.Hour)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "partner", id.Client)
+
+	w, _ = serveAuthenticated(t, conf, signer.Sign("partner", time.Now().Add(-time.Second)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, decodeError(t, w).Message, "token expired")
+}
+
+func TestRateLimiterAuthenticatedClient(t *testing.T) {
+	limiter, err := NewRateLimiter(&config.RateLimitConfig{
+		Requests: 1,
+		Window:   time.Minute,
+		Key:      config.RateLimitKeyAPIKey,
+		Backend:  config.RateLimitMemory,
+	})
+	assert.NoError(t, err)
+
+	r := newTranslateRequest("sl=en&tl=es", "Hello")
+	r.Header.Set("Authorization", "Bearer key-a")
+	// unverified tokens are not trusted
+	assert.Equal(t, "ip:192.0.2.1", limiter.clientKey(r))
+
+	r = r.WithContext(auth.NewContext(r.Context(), auth.Identity{Client: "ios", Method: auth.MethodAPIKey}))
+	assert.Equal(t, "client:ios", limiter.clientKey(r))
+}
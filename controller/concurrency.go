@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons for shedding a request, used as metric label.
+const (
+	shedQueueFull    = "queue_full"
+	shedQueueTimeout = "queue_timeout"
+)
+
+// shedRetryAfter is the Retry-After value, in seconds, of shed requests.
+const shedRetryAfter = "1"
+
+var (
+	// QueueSize is the maximum number of requests waiting for an endpoint at
+	// its concurrency limit.
+	QueueSize = 16
+	// QueueTimeout is how long a request waits in the queue before it is shed.
+	QueueTimeout = 100 * time.Millisecond
+
+	errQueueFull    = errors.New("queue full")
+	errQueueTimeout = errors.New("timed out in queue")
+
+	upstreamQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "translate_upstream_queue_depth",
+		Help: "The number of requests waiting for an endpoint at its concurrency limit",
+	},
+		[]string{"endpoint"},
+	)
+	upstreamShed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_shed_requests_total",
+		Help: "The total number of requests rejected because an endpoint was at its concurrency limit, by reason",
+	},
+		[]string{"endpoint", "reason"},
+	)
+	upstreamRerouted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_rerouted_requests_total",
+		Help: "The total number of requests sent to another endpoint because the selected one was at its concurrency limit",
+	},
+		[]string{"endpoint"},
+	)
+)
+
+// slots bounds the number of requests in flight to an endpoint. Requests over
+// the limit wait in a FIFO queue, and are handed the slot of the request
+// finishing first.
+type slots struct {
+	mu     sync.Mutex
+	active int
+	queue  []chan struct{}
+}
+
+// tryAcquire takes a slot if one is free and no request is waiting. A limit of
+// 0 means no limit. The returned function releases the slot.
+func (s *slots) tryAcquire(limit int) (func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit > 0 && (s.active >= limit || len(s.queue) > 0) {
+		return nil, false
+	}
+	s.active++
+	return func() { s.release(limit) }, true
+}
+
+// acquire takes a slot, waiting up to timeout in a queue of at most queueSize
+// requests if none is free.
+func (s *slots) acquire(ctx context.Context, endpoint string, limit, queueSize int, timeout time.Duration) (func(), error) {
+	// a free slot is taken, or the queue joined, at once so that no slot can
+	// be released in between
+	s.mu.Lock()
+	if limit <= 0 || (s.active < limit && len(s.queue) == 0) {
+		s.active++
+		s.mu.Unlock()
+		return func() { s.release(limit) }, nil
+	}
+	if len(s.queue) >= queueSize {
+		s.mu.Unlock()
+		return nil, errQueueFull
+	}
+	ready := make(chan struct{})
+	s.queue = append(s.queue, ready)
+	upstreamQueueDepth.With(prometheus.Labels{"endpoint": endpoint}).Set(float64(len(s.queue)))
+	s.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return func() { s.release(limit) }, nil
+	case <-timer.C:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.Index(s.queue, ready)
+	if i < 0 {
+		// the slot was handed over while giving up
+		return func() { s.release(limit) }, nil
+	}
+	s.queue = slices.Delete(s.queue, i, i+1)
+	upstreamQueueDepth.With(prometheus.Labels{"endpoint": endpoint}).Set(float64(len(s.queue)))
+	return nil, err
+}
+
+// release frees a slot, handing it to the first waiting request unless the
+// limit was lowered meanwhile.
+func (s *slots) release(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) > 0 && (limit <= 0 || s.active <= limit) {
+		ready := s.queue[0]
+		s.queue = s.queue[1:]
+		close(ready)
+		return
+	}
+	s.active--
+}
+
+// maxConcurrency returns the maximum number of requests in flight to the
+// endpoint, 0 meaning no limit.
+func (c *LnxEndpointConfiguration) maxConcurrency(endpoint string) int {
+	endpointConf, ok := c.EndpointConfigs[endpoint]
+	if !ok || endpointConf.MaxConcurrency == nil {
+		return 0
+	}
+	return *endpointConf.MaxConcurrency
+}
+
+// overflowEndpoints returns the endpoints other than endpoint which may serve
+// the language pair, by decreasing weight.
+func (c *LnxEndpointConfiguration) overflowEndpoints(endpoint, from, to string) []string {
+	weights := c.LanguagePairWeights[from][to]
+	var endpoints []string
+	for _, other := range c.Endpoints {
+		if other != endpoint && weights[other] > 0 && !isDrained(other) {
+			endpoints = append(endpoints, other)
+		}
+	}
+	slices.SortStableFunc(endpoints, func(a, b string) int {
+		switch {
+		case weights[a] > weights[b]:
+			return -1
+		case weights[a] < weights[b]:
+			return 1
+		}
+		return 0
+	})
+	return endpoints
+}
+
+// acquireEndpoint takes a slot of the endpoint, or of another endpoint serving
+// the language pair if it is at its concurrency limit. If all of them are, the
+// request waits for a slot of the endpoint in its queue. The endpoint used and
+// the function releasing the slot are returned, or an error response is
+// written and false returned if the request was shed.
+func acquireEndpoint(w http.ResponseWriter, r *http.Request, conf *LnxEndpointConfiguration, endpoint, from, to string) (string, func(), bool) {
+	state := stateOf(endpoint)
+	limit := conf.maxConcurrency(endpoint)
+	if release, ok := state.slots.tryAcquire(limit); ok {
+		return endpoint, release, true
+	}
+
+	for _, other := range conf.overflowEndpoints(endpoint, from, to) {
+		if release, ok := stateOf(other).slots.tryAcquire(conf.maxConcurrency(other)); ok {
+			upstreamRerouted.With(prometheus.Labels{"endpoint": endpoint}).Inc()
+			return other, release, true
+		}
+	}
+
+	release, err := state.slots.acquire(r.Context(), endpoint, limit, QueueSize, QueueTimeout)
+	if err == nil {
+		return endpoint, release, true
+	}
+	if r.Context().Err() != nil {
+		// the client is gone or the router timeout already responded
+		return "", nil, false
+	}
+
+	reason := shedQueueFull
+	if errors.Is(err, errQueueTimeout) {
+		reason = shedQueueTimeout
+	}
+	upstreamShed.With(prometheus.Labels{"endpoint": endpoint, "reason": reason}).Inc()
+	w.Header().Set("Retry-After", shedRetryAfter)
+	writeError(w, r, ErrUnavailable, "LnxEndpoint overloaded", err)
+	return "", nil, false
+}
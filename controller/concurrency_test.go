@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/config"
+	"github.com/brave/go-translate/language"
+)
+
+func TestSlots(t *testing.T) {
+	var s slots
+	ctx := context.Background()
+
+	release, ok := s.tryAcquire(1)
+	assert.True(t, ok)
+	_, ok = s.tryAcquire(1)
+	assert.False(t, ok)
+
+	_, err := s.acquire(ctx, "endpoint", 1, 0, time.Second)
+	assert.ErrorIs(t, err, errQueueFull)
+	_, err = s.acquire(ctx, "endpoint", 1, 1, 10*time.Millisecond)
+	assert.ErrorIs(t, err, errQueueTimeout)
+
+	// the slot is handed to the waiting request
+	acquired := make(chan func())
+	go func() {
+		release, err := s.acquire(ctx, "endpoint", 1, 1, time.Second)
+		assert.NoError(t, err)
+		acquired <- release
+	}()
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.queue) == 1
+	}, time.Second, time.Millisecond)
+	release()
+	(<-acquired)()
+
+	assert.Equal(t, 0, s.active)
+	assert.Empty(t, s.queue)
+
+	// no limit
+	for range 3 {
+		_, ok = s.tryAcquire(0)
+		assert.True(t, ok)
+	}
+}
+
+func TestSlotsReleasedBeforeQueueing(t *testing.T) {
+	var s slots
+	release, ok := s.tryAcquire(1)
+	assert.True(t, ok)
+	_, ok = s.tryAcquire(1)
+	assert.False(t, ok)
+
+	// the holder releases its slot before the request which found none free
+	// joins the queue
+	release()
+	next, err := s.acquire(context.Background(), "endpoint", 1, 1, 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, s.active)
+	assert.Empty(t, s.queue)
+	next()
+
+	// no request waits for its timeout while slots are released concurrently
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.acquire(context.Background(), "endpoint", 2, 50, 5*time.Second)
+			if assert.NoError(t, err) {
+				release()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 0, s.active)
+	assert.Empty(t, s.queue)
+}
+
+func setupLimitedEndpoints(t *testing.T, limit int, handlers ...http.HandlerFunc) []string {
+	list := language.GoogleLanguageList{
+		Sl: map[string]string{"en": "English", "es": "Spanish"},
+		Tl: map[string]string{"en": "English", "es": "Spanish"},
+	}
+	var endpointConfs []config.EndpointConfig
+	var lists []language.GoogleLanguageList
+	var urls []string
+	for i, handler := range handlers {
+		ts := httptest.NewServer(handler)
+		t.Cleanup(ts.Close)
+		// the other endpoints are all but never selected
+		weight := 1.0
+		if i > 0 {
+			weight = 0.000001
+		}
+		endpointConfs = append(endpointConfs, config.EndpointConfig{URL: ts.URL, Weight: &weight, MaxConcurrency: &limit})
+		lists = append(lists, list)
+		urls = append(urls, ts.URL)
+	}
+	conf, err := NewLnxEndpointConfigurationFromConfig(endpointConfs, lists)
+	assert.NoError(t, err)
+	SetLnxEndpoint(conf)
+	return urls
+}
+
+func translateOK(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"sourceText":["Hello"],"translatedText":["Hola"]}`))
+}
+
+func TestTranslateOverflow(t *testing.T) {
+	served := make(chan int, 1)
+	endpoints := setupLimitedEndpoints(t, 1,
+		func(w http.ResponseWriter, r *http.Request) { served <- 0; translateOK(w, r) },
+		func(w http.ResponseWriter, r *http.Request) { served <- 1; translateOK(w, r) },
+	)
+
+	// hold the only slot of the first endpoint
+	release, ok := stateOf(endpoints[0]).slots.tryAcquire(1)
+	assert.True(t, ok)
+	defer release()
+
+	before := testutil.ToFloat64(upstreamRerouted.WithLabelValues(endpoints[0]))
+	w := httptest.NewRecorder()
+	Translate(w, newTranslateRequest("sl=en&tl=es", "Hello"))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, <-served)
+	assert.Equal(t, before+1, testutil.ToFloat64(upstreamRerouted.WithLabelValues(endpoints[0])))
+}
+
+func TestTranslateShed(t *testing.T) {
+	endpoints := setupLimitedEndpoints(t, 1, translateOK)
+
+	queueTimeout := QueueTimeout
+	QueueTimeout = 10 * time.Millisecond
+	defer func() { QueueTimeout = queueTimeout }()
+
+	release, ok := stateOf(endpoints[0]).slots.tryAcquire(1)
+	assert.True(t, ok)
+
+	before := testutil.ToFloat64(upstreamShed.WithLabelValues(endpoints[0], shedQueueTimeout))
+	w := httptest.NewRecorder()
+	Translate(w, newTranslateRequest("sl=en&tl=es", "Hello"))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.True(t, decodeError(t, w).Retryable)
+	assert.Equal(t, before+1, testutil.ToFloat64(upstreamShed.WithLabelValues(endpoints[0], shedQueueTimeout)))
+
+	// requests go through again once the slot is released
+	release()
+	w = httptest.NewRecorder()
+	Translate(w, newTranslateRequest("sl=en&tl=es", "Hello"))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -4,34 +4,72 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"math/rand"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/brave-intl/bat-go/libs/logging"
 	"github.com/brave-intl/bat-go/libs/middleware"
+	"github.com/brave/go-translate/config"
 	"github.com/brave/go-translate/language"
+	"github.com/brave/go-translate/tracing"
 	"github.com/brave/go-translate/translate"
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
-	// LnxEndpoint stores the configuration for Lingvanex translation service endpoints
-	LnxEndpoint   *LnxEndpointConfiguration
-	// LnxAPIKey is the API key for accessing Lingvanex translation services
-	LnxAPIKey     = os.Getenv("LNX_API_KEY")
+	// lnxEndpoint stores the configuration for Lingvanex translation service endpoints.
+	// It is swapped atomically on reload, so requests keep using the configuration
+	// they started with.
+	lnxEndpoint atomic.Pointer[LnxEndpointConfiguration]
+	// lnxEndpointMu serializes updates of lnxEndpoint.
+	lnxEndpointMu sync.Mutex
 	languagePath  = "/get-languages"
 	translatePath = "/translate"
 	// MaxResponseSize limits the size of response bodies
 	MaxResponseSize = int64(5 * 1024 * 1024) // 5MB
+	// UpstreamTimeout bounds a single translate round-trip to Lingvanex,
+	// independently of the router timeout.
+	UpstreamTimeout = 30 * time.Second
+
+	endpointReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_endpoint_config_reloads_total",
+		Help: "The total number of Lingvanex endpoint configuration reloads, by result",
+	},
+		[]string{"result"},
+	)
+	upstreamCancelled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_requests_cancelled_total",
+		Help: "The total number of Lingvanex requests cut short before completing, by reason",
+	},
+		[]string{"reason"},
+	)
 )
 
+const (
+	// the browser went away before the upstream call completed
+	cancelReasonClient = "client_disconnected"
+	// the router timeout fired before the upstream call completed
+	cancelReasonServer = "server_timeout"
+	// the upstream deadline was exceeded
+	cancelReasonUpstream = "upstream_timeout"
+)
+
+// autoDetect is the source language of requests asking Lingvanex to detect
+// it, which every endpoint supports.
+const autoDetect = "auto"
+
 // LnxEndpointConfiguration describes a configuration of lingvanex endpoints, their supported
 // languages and weights.
 type LnxEndpointConfiguration struct {
@@ -45,6 +83,10 @@ type LnxEndpointConfiguration struct {
 	// The first key represents the source language, the second key represents the target language, the
 	// third key represents the endpoint URL and the value the corresponding weight for that endpoint.
 	LanguagePairWeights map[string]map[string]map[string]float64
+	// The endpoint descriptions, keyed by endpoint URL.
+	EndpointConfigs map[string]config.EndpointConfig
+	// The API keys of the endpoints, keyed by endpoint URL.
+	credentials map[string]*config.Credential
 }
 
 // NewLnxEndpointConfiguration returns a new endpoint configuration based on a list of endpoints, weights and list of supported languages
@@ -53,21 +95,58 @@ func NewLnxEndpointConfiguration(endpoints []string, weights []float64, language
 		return nil, fmt.Errorf("number of endpoints must match number of weights and number of language lists")
 	}
 
+	endpointConfs := make([]config.EndpointConfig, len(endpoints))
+	for i, endpoint := range endpoints {
+		endpointConfs[i] = config.EndpointConfig{URL: endpoint, Weight: &weights[i]}
+	}
+	return NewLnxEndpointConfigurationFromConfig(endpointConfs, languageLists)
+}
+
+// NewLnxEndpointConfigurationFromConfig returns a new endpoint configuration based on a list of endpoint
+// descriptions and the list of languages supported by each of them
+func NewLnxEndpointConfigurationFromConfig(endpointConfs []config.EndpointConfig, languageLists []language.GoogleLanguageList) (*LnxEndpointConfiguration, error) {
+	if len(endpointConfs) != len(languageLists) {
+		return nil, fmt.Errorf("number of endpoints must match number of language lists")
+	}
+
 	conf := LnxEndpointConfiguration{
-		Endpoints:           endpoints,
-		DefaultWeights:      weights,
+		Endpoints:           make([]string, len(endpointConfs)),
+		DefaultWeights:      make([]float64, len(endpointConfs)),
 		LanguagePairList:    language.GoogleLanguageList{Sl: make(map[string]string), Tl: make(map[string]string)},
 		LanguagePairWeights: make(map[string]map[string]map[string]float64),
+		EndpointConfigs:     make(map[string]config.EndpointConfig, len(endpointConfs)),
+		credentials:         make(map[string]*config.Credential, len(endpointConfs)),
 	}
 
-	for i, endpoint := range endpoints {
+	for i, endpointConf := range endpointConfs {
+		endpoint := endpointConf.URL
+		if _, ok := conf.EndpointConfigs[endpoint]; ok {
+			return nil, fmt.Errorf("duplicate endpoint %q", endpoint)
+		}
+		conf.Endpoints[i] = endpoint
+		conf.DefaultWeights[i] = endpointConf.DefaultWeight()
+		conf.EndpointConfigs[endpoint] = endpointConf
+		conf.credentials[endpoint] = config.NewCredential(endpointConf.APIKey, endpointConf.APIKeyFile)
+
 		// get the list of supported languages for the current endpoint
 		list := languageLists[i]
 
-		// iterate through the source languages the current endpoint supports
-		for sl, sldesc := range list.Sl {
+		// add the target language descriptions to the merged language pair list
+		for tl, tldesc := range list.Tl {
+			conf.LanguagePairList.Tl[tl] = tldesc
+		}
+
+		// iterate through the source languages the current endpoint supports,
+		// every endpoint also detects the source language
+		sources := slices.Collect(maps.Keys(list.Sl))
+		if _, ok := list.Sl[autoDetect]; !ok && len(list.Tl) > 0 {
+			sources = append(sources, autoDetect)
+		}
+		for _, sl := range sources {
 			// add the source language description to the merged language pair list
-			conf.LanguagePairList.Sl[sl] = sldesc
+			if sldesc, ok := list.Sl[sl]; ok {
+				conf.LanguagePairList.Sl[sl] = sldesc
+			}
 
 			// check if the source language weight map already exists in the language pair weights
 			if _, ok := conf.LanguagePairWeights[sl]; !ok {
@@ -75,24 +154,36 @@ func NewLnxEndpointConfiguration(endpoints []string, weights []float64, language
 				conf.LanguagePairWeights[sl] = make(map[string]map[string]float64)
 			}
 
-			for tl, tldesc := range list.Tl {
-				// add the target language description to the merged language pair list
-				conf.LanguagePairList.Tl[tl] = tldesc
+			for tl := range list.Tl {
+				// skip the language pairs which must not be routed to the current endpoint
+				if !endpointConf.Allows(sl, tl) {
+					continue
+				}
 
 				// check if the weight map for the source / target language pair already exists
 				if _, ok := conf.LanguagePairWeights[sl][tl]; !ok {
 					// if not, create a new weight map for it
 					conf.LanguagePairWeights[sl][tl] = make(map[string]float64)
 				}
-				// set the default weight for the current endpoint for the source-target language pair
-				conf.LanguagePairWeights[sl][tl][endpoint] = conf.DefaultWeights[i]
+				// set the weight for the current endpoint for the source-target language pair
+				conf.LanguagePairWeights[sl][tl][endpoint] = endpointConf.PairWeight(sl, tl)
 			}
 		}
 	}
 	return &conf, nil
 }
 
+// APIKey returns the API key which should be sent to the endpoint.
+func (c *LnxEndpointConfiguration) APIKey(endpoint string) (config.Secret, error) {
+	cred, ok := c.credentials[endpoint]
+	if !ok {
+		return "", nil
+	}
+	return cred.Get()
+}
+
 // GetEndpoint returns the endpoint which should be used based on the weights and languages supported.
+// An empty string is returned if no endpoint may serve the language pair.
 func (c *LnxEndpointConfiguration) GetEndpoint(from, to string) string {
 	// initialize total weight and incrementals.
 	total := 0.0
@@ -102,8 +193,11 @@ func (c *LnxEndpointConfiguration) GetEndpoint(from, to string) string {
 	weights := c.LanguagePairWeights[from][to]
 
 	// iterate through the Endpoints array, accumulating the total weight and storing the intermediate sums in incrementals.
+	// drained endpoints don't receive new requests.
 	for _, endpoint := range c.Endpoints {
-		total += weights[endpoint]
+		if !isDrained(endpoint) {
+			total += weights[endpoint]
+		}
 		incrementals = append(incrementals, total)
 	}
 
@@ -116,90 +210,163 @@ func (c *LnxEndpointConfiguration) GetEndpoint(from, to string) string {
 			return c.Endpoints[i]
 		}
 	}
-	// otherwise default to the first endpoint
-	return c.Endpoints[0]
+	// otherwise default to the first endpoint serving the language pair, e.g.
+	// if all of their weights are 0
+	for _, endpoint := range c.Endpoints {
+		if _, ok := weights[endpoint]; ok && !isDrained(endpoint) {
+			return endpoint
+		}
+	}
+	return ""
+}
+
+// supportsPair reports whether any endpoint serves the language pair,
+// including drained ones.
+func (c *LnxEndpointConfiguration) supportsPair(from, to string) bool {
+	return len(c.LanguagePairWeights[from][to]) > 0
 }
 
 // TranslateRouter add routers for translate requests and translate script
 // requests.
-func TranslateRouter(ctx context.Context) (chi.Router, error) {
+func TranslateRouter(ctx context.Context, conf *config.Config) (chi.Router, error) {
 	r := chi.NewRouter()
 
-	var weights []float64
-	endpoints := strings.Split(os.Getenv("LNX_HOST"), ",")
-	for _, weight := range strings.Split(os.Getenv("LNX_WEIGHTS"), ",") {
-		if len(weight) > 0 {
-			weight, err := strconv.ParseFloat(weight, 64)
-			if err != nil {
-				return r, fmt.Errorf("must pass at least one endpoint via LNX_HOST and one weight via LNX_WEIGHTS: %v", err)
-			}
-			weights = append(weights, weight)
-		}
-	}
-	if len(endpoints) == 1 && len(weights) == 0 {
-		weights = append(weights, 1)
+	MaxResponseSize = conf.MaxResponseSize
+	UpstreamTimeout = conf.Lnx.UpstreamTimeout
+	UpstreamPairLabels = conf.Lnx.MetricsPairLabels
+	QueueSize = conf.Lnx.QueueSize
+	QueueTimeout = conf.Lnx.QueueTimeout
+	StaticMaxAge = conf.Static.MaxAge
+	ScriptOrigins = conf.Static.Origins
+	if len(conf.AssetsDir) > 0 {
+		staticFiles = newStaticAssets(os.DirFS(conf.AssetsDir))
 	}
+	SetTransportConfig(conf.Lnx.Transport)
 
-	var lists []language.GoogleLanguageList
-	for _, endpoint := range endpoints {
-		list, err := getLanguageList(ctx, endpoint)
-		if err != nil {
-			panic(err)
-		}
-		lists = append(lists, *list)
+	err := ReloadLnxEndpoint(ctx, &conf.Lnx)
+	if err != nil {
+		return r, fmt.Errorf("failed to setup endpoint configuration: %v", err)
 	}
 
-	var err error
-	LnxEndpoint, err = NewLnxEndpointConfiguration(endpoints, weights, lists)
+	var translateHandler http.Handler = http.HandlerFunc(Translate)
+	limiter, err := NewRateLimiter(&conf.RateLimit)
 	if err != nil {
-		return r, fmt.Errorf("failed to setup endpoint configuration: %v", err)
+		return r, fmt.Errorf("failed to setup rate limiter: %v", err)
 	}
+	if limiter != nil {
+		translateHandler = limiter.Middleware(translateHandler)
+	}
+	// oversized requests are rejected before the rate limiter parses them
+	translateHandler = LimitRequestSize(&conf.Limits)(translateHandler)
+	// clients are authenticated first, so that they can be limited by identity
+	translateHandler = Authenticate(NewAuthenticator(&conf.Auth))(translateHandler)
 
-	r.Post("/translate_a/t", middleware.InstrumentHandler("Translate", http.HandlerFunc(Translate)).ServeHTTP)
+	r.Post("/translate_a/t", middleware.InstrumentHandler("Translate", translateHandler).ServeHTTP)
 	r.Get("/translate_a/l", middleware.InstrumentHandler("GetLanguageList", http.HandlerFunc(GetLanguageList)).ServeHTTP)
 
-	r.Get("/static/v1/element.js", middleware.InstrumentHandler("ServeStaticFile", http.HandlerFunc(ServeStaticFile)).ServeHTTP)
-	r.Get("/static/v1/js/element/main.js", middleware.InstrumentHandler("ServeStaticFile", http.HandlerFunc(ServeStaticFile)).ServeHTTP)
-	r.Get("/static/v1/css/translateelement.css", middleware.InstrumentHandler("ServeStaticFile", http.HandlerFunc(ServeStaticFile)).ServeHTTP)
+	if err := staticFiles.preload(staticPaths, ScriptOrigins); err != nil {
+		return r, err
+	}
+	for _, p := range staticPaths {
+		r.Get(p, middleware.InstrumentHandler("ServeStaticFile", http.HandlerFunc(ServeStaticFile)).ServeHTTP)
+		if conf.Static.HashedURLs {
+			r.Get(hashedPath(p), middleware.InstrumentHandler("ServeStaticFile", http.HandlerFunc(ServeHashedStaticFile)).ServeHTTP)
+		}
+	}
 
 	return r, nil
 }
 
-// ServeStaticFile serves static files from the assets directory for the translation script
-func ServeStaticFile(w http.ResponseWriter, r *http.Request) {
-	workDir, _ := os.Getwd()
-	filesDir := http.Dir(filepath.Join(workDir, "assets"))
-	fs := http.FileServer(filesDir)
+// CurrentLnxEndpoint returns the endpoint configuration currently in use.
+func CurrentLnxEndpoint() *LnxEndpointConfiguration {
+	return lnxEndpoint.Load()
+}
 
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Content-Security-Policy", "require-trusted-types-for 'script'")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
-	w.Header().Set("Cache-Control", "max-age=86400")
-	w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+// SetLnxEndpoint replaces the endpoint configuration in use. Requests in
+// flight finish with the configuration they started with.
+func SetLnxEndpoint(conf *LnxEndpointConfiguration) {
+	lnxEndpointMu.Lock()
+	defer lnxEndpointMu.Unlock()
 
-	fs.ServeHTTP(w, r)
+	lnxEndpoint.Store(conf)
 }
 
-func getHTTPClient() *http.Client {
-	return &http.Client{
-		Timeout: time.Second * 60,
+// updateLnxEndpoint replaces the endpoint configuration in use by the one
+// returned by update, which must not modify the configuration it is given.
+func updateLnxEndpoint(update func(*LnxEndpointConfiguration) (*LnxEndpointConfiguration, error)) error {
+	lnxEndpointMu.Lock()
+	defer lnxEndpointMu.Unlock()
+
+	conf, err := update(lnxEndpoint.Load())
+	if err != nil {
+		return err
 	}
+	lnxEndpoint.Store(conf)
+	return nil
 }
 
-func getLanguageList(ctx context.Context, endpoint string) (*language.GoogleLanguageList, error) {
+// ReloadLnxEndpoint builds a new endpoint configuration, fetching the language
+// list of every endpoint, and swaps it in. The current configuration is kept
+// if any endpoint fails to respond.
+func ReloadLnxEndpoint(ctx context.Context, conf *config.LnxConfig) error {
+	endpointConfs := slices.Clone(conf.EndpointConfigs())
+
+	var lists []language.GoogleLanguageList
+	for i := range endpointConfs {
+		// fall back to the shared API key and concurrency limit
+		if len(endpointConfs[i].APIKey) == 0 && len(endpointConfs[i].APIKeyFile) == 0 {
+			endpointConfs[i].APIKey = conf.APIKey
+			endpointConfs[i].APIKeyFile = conf.APIKeyFile
+		}
+		if endpointConfs[i].MaxConcurrency == nil {
+			maxConcurrency := conf.MaxConcurrency
+			endpointConfs[i].MaxConcurrency = &maxConcurrency
+		}
+		cred := config.NewCredential(endpointConfs[i].APIKey, endpointConfs[i].APIKeyFile)
+		list, err := getLanguageList(ctx, endpointConfs[i].URL, cred)
+		if err != nil {
+			err = fmt.Errorf("failed to get language list of %s: %v", endpointConfs[i].URL, err)
+			recordReload(err)
+			return err
+		}
+		lists = append(lists, *list)
+	}
+
+	endpointConf, err := NewLnxEndpointConfigurationFromConfig(endpointConfs, lists)
+	if err != nil {
+		recordReload(err)
+		return err
+	}
+	SetLnxEndpoint(endpointConf)
+	recordReload(nil)
+	return nil
+}
+
+func getLanguageList(ctx context.Context, endpoint string, cred *config.Credential) (list *language.GoogleLanguageList, err error) {
 	logger := logging.FromContext(ctx)
 
+	ctx, span := tracing.Start(ctx, "lnx_languages", attribute.String("lnx.endpoint", endpoint))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	// Send a get language list request to Lnx
 	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+languagePath, nil)
-	req.Header.Add("Authorization", "Bearer "+LnxAPIKey)
-
 	if err != nil {
 		return nil, fmt.Errorf("error creating Lnx request: %v", err)
 	}
+	apiKey, err := cred.Get()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+string(apiKey))
+	tracing.Inject(ctx, req.Header)
 
-	client := getHTTPClient()
+	client := getHTTPClient(endpoint)
+	observer := observeUpstream(req, endpoint, operationLanguages, "", "")
 	lnxResp, err := client.Do(req)
+	observer.done(lnxResp, err)
 	if err != nil {
 		return nil, fmt.Errorf("error sending request to Lnx server: %v", err)
 	}
@@ -210,12 +377,17 @@ func getLanguageList(ctx context.Context, endpoint string) (*language.GoogleLang
 		}
 	}()
 
+	if lnxResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected Lnx response status %s", lnxResp.Status)
+	}
+
 	// Convert to google format language list and write it back
-	lnxBody, err := io.ReadAll(io.LimitReader(lnxResp.Body, MaxResponseSize))
+	lnxBody, err := readUpstreamBody(lnxResp)
+	observer.responseBytes(len(lnxBody))
 	if err != nil {
 		return nil, fmt.Errorf("error reading Lnx response body: %v", err)
 	}
-	list, err := language.ToGoogleLanguageList(lnxBody)
+	list, err = language.ToGoogleLanguageList(lnxBody)
 	if err != nil {
 		return nil, fmt.Errorf("error converting to google language list: %v", err)
 	}
@@ -228,72 +400,196 @@ func getLanguageList(ctx context.Context, endpoint string) (*language.GoogleLang
 func GetLanguageList(w http.ResponseWriter, r *http.Request) {
 	logger := logging.FromContext(r.Context())
 
-	body, err := json.Marshal(LnxEndpoint.LanguagePairList)
+	body, err := json.Marshal(CurrentLnxEndpoint().LanguagePairList)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		handleInternalServerError(w, r, "Error marshalling language list", err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	_, err = w.Write(body)
 	if err != nil {
 		logger.Error().Err(err).Msg("Error writing response body for translate requests")
 	}
 }
 
-// handleBadRequestError writes a 400 Bad Request error response
-func handleBadRequestError(w http.ResponseWriter, message string, err error) {
-	http.Error(w, fmt.Sprintf("%s: %v", message, err), http.StatusBadRequest)
-}
+// errMalformedResponse is returned for successful Lingvanex responses which
+// can't be used.
+var errMalformedResponse = errors.New("malformed LnxEndpoint response")
 
-// handleInternalServerError writes a 500 Internal Server Error response
-func handleInternalServerError(w http.ResponseWriter, message string, err error) {
-	http.Error(w, fmt.Sprintf("%s: %v", message, err), http.StatusInternalServerError)
-}
+// Translate converts a Google format translate request into a Lingvanex format
+// one which will be send to the Lingvanex server, and write a Google format
+// response back to the client.
+func Translate(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 
-// handleNonOKResponse handles responses with non-OK status codes
-func handleNonOKResponse(w http.ResponseWriter, lnxResp *http.Response) {
-	w.WriteHeader(lnxResp.StatusCode)
-	_, err := w.Write([]byte("LNX-ERROR:\n"))
+	from, to, err := translate.GetLanguageParams(r)
 	if err != nil {
-		handleInternalServerError(w, "Error writing error message", err)
+		handleBadRequestError(w, r, "error converting to LnxEndpoint request", err)
 		return
 	}
-	_, err = io.Copy(w, lnxResp.Body)
-	if err != nil {
-		handleInternalServerError(w, "Error copying LnxEndpoint response body", err)
+
+	// Nothing to translate, echo the input segments back without a round-trip
+	if translate.IsSameLanguage(from, to) {
+		body, err := translate.ToSameLanguageResponseBody(r)
+		if err != nil {
+			handleBadRequestError(w, r, "error parsing translate request", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, err = w.Write(body)
+		if err != nil {
+			logger.Error().Err(err).Msg("Error writing response body for translate requests")
+		}
+		return
 	}
-}
 
-// Translate converts a Google format translate request into a Lingvanex format
-// one which will be send to the Lingvanex server, and write a Google format
-// response back to the client.
-func Translate(w http.ResponseWriter, r *http.Request) {
-	logger := logging.FromContext(r.Context())
+	upstreamCtx, cancel := context.WithTimeout(r.Context(), UpstreamTimeout)
+	defer cancel()
 
-	w.Header().Set("Access-Control-Allow-Origin", "*") // same as Google response
+	endpointConf := CurrentLnxEndpoint()
+	endpoint, ok := selectEndpoint(w, r, endpointConf, from, to)
+	if !ok {
+		return
+	}
+	endpoint, release, ok := acquireEndpoint(w, r, endpointConf, endpoint, from, to)
+	if !ok {
+		return
+	}
+	defer release()
+	upstreamReq := r.WithContext(upstreamCtx)
+	req, isAuto, ok := buildUpstreamRequest(w, upstreamReq, endpointConf, endpoint)
+	if !ok {
+		return
+	}
+	// the form was parsed into the copy of the request by buildUpstreamRequest
+	segments := len(upstreamReq.PostForm["q"])
+	lnxBody, ok := doUpstreamRequest(w, r, req, endpoint, from, to)
+	if !ok {
+		return
+	}
 
-	to, from, err := translate.GetLanguageParams(r)
+	// Set google format response body
+	_, span := tracing.Start(r.Context(), "convert_response")
+	body, err := translate.ToGoogleResponseBody(lnxBody, isAuto, to, segments)
 	if err != nil {
-		handleBadRequestError(w, "error converting to LnxEndpoint request", err)
+		err = fmt.Errorf("%w: %v", errMalformedResponse, err)
+		tracing.RecordError(span, err)
+		span.End()
+		stateOf(endpoint).recordFailure(err.Error())
+		writeError(w, r, ErrUpstream, "Error converting to google response body", err)
 		return
 	}
+	span.End()
+	stateOf(endpoint).recordSuccess()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(body)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error writing response body for translate requests")
+	}
+}
+
+// readUpstreamBody reads the body of a successful Lingvanex response, which
+// must not be empty nor exceed MaxResponseSize. The Content-Type of the
+// response is ignored, the body is validated when it is converted.
+func readUpstreamBody(lnxResp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(lnxResp.Body, MaxResponseSize+1))
+	if err != nil {
+		return body, err
+	}
+	if int64(len(body)) > MaxResponseSize {
+		return body[:MaxResponseSize], fmt.Errorf("%w: body exceeds %d bytes", errMalformedResponse, MaxResponseSize)
+	}
+	if len(body) == 0 {
+		return body, fmt.Errorf("%w: empty body", errMalformedResponse)
+	}
+	return body, nil
+}
+
+// selectEndpoint returns the endpoint the language pair should be sent to, or
+// writes an error response and returns false if there is none.
+func selectEndpoint(w http.ResponseWriter, r *http.Request, endpointConf *LnxEndpointConfiguration, from, to string) (string, bool) {
+	_, span := tracing.Start(r.Context(), "select_endpoint",
+		attribute.String("translate.from", from),
+		attribute.String("translate.to", to),
+	)
+	defer span.End()
+
+	endpoint := endpointConf.GetEndpoint(from, to)
+	if endpoint == "" {
+		err := fmt.Errorf("unsupported language pair %s:%s", from, to)
+		tracing.RecordError(span, err)
+		if endpointConf.supportsPair(from, to) {
+			writeError(w, r, ErrUnavailable, fmt.Sprintf("no LnxEndpoint available for language pair %s:%s", from, to), nil)
+			return "", false
+		}
+		writeError(w, r, ErrUnsupportedPair, "error converting to LnxEndpoint request", err)
+		return "", false
+	}
+	span.SetAttributes(attribute.String("lnx.endpoint", endpoint))
+	return endpoint, true
+}
+
+// buildUpstreamRequest parses the translate request and returns the Lingvanex
+// request for the endpoint, or writes an error response and returns false.
+func buildUpstreamRequest(w http.ResponseWriter, r *http.Request, endpointConf *LnxEndpointConfiguration, endpoint string) (*http.Request, bool, bool) {
+	_, span := tracing.Start(r.Context(), "parse_request")
+	defer span.End()
 
-	endpoint := LnxEndpoint.GetEndpoint(from, to)
 	req, isAuto, err := translate.ToLingvanexRequest(r, endpoint+translatePath)
 	if err != nil {
-		handleBadRequestError(w, "error converting to LnxEndpoint request", err)
-		return
+		tracing.RecordError(span, err)
+		handleBadRequestError(w, r, "error converting to LnxEndpoint request", err)
+		return nil, false, false
+	}
+
+	apiKey, err := endpointConf.APIKey(endpoint)
+	if err != nil {
+		tracing.RecordError(span, err)
+		handleInternalServerError(w, r, "error reading LnxEndpoint credentials", err)
+		return nil, false, false
 	}
+	req.Header.Add("Authorization", "Bearer "+string(apiKey))
+	return req, isAuto, true
+}
 
-	req.Header.Add("Authorization", "Bearer "+LnxAPIKey)
+// doUpstreamRequest sends the request to the endpoint and returns the body of
+// a successful response, or writes an error response and returns false. The
+// success is recorded by the caller once the body was converted.
+func doUpstreamRequest(w http.ResponseWriter, r *http.Request, req *http.Request, endpoint, from, to string) ([]byte, bool) {
+	// every log line of the upstream call carries the endpoint
+	logger := logging.FromContext(r.Context()).With().Str("endpoint", endpoint).Logger()
+	r = r.WithContext(logger.WithContext(r.Context()))
+	upstreamCtx := req.Context()
+
+	ctx, span := tracing.Start(upstreamCtx, "lnx_request",
+		attribute.String("lnx.endpoint", endpoint),
+	)
+	defer span.End()
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
+	setUpstreamRequestID(r.Context(), req.Header)
+
+	state := stateOf(endpoint)
+	state.inFlight.Add(1)
+	defer state.inFlight.Add(-1)
 
 	// Send translate request to Lnx server
-	client := getHTTPClient()
+	client := getHTTPClient(endpoint)
+	observer := observeUpstream(req, endpoint, operationTranslate, from, to)
 	lnxResp, err := client.Do(req)
+	observer.done(lnxResp, err)
 	if err != nil {
-		handleInternalServerError(w, "error sending request to LnxEndpoint", err)
-		return
+		tracing.RecordError(span, err)
+		// only count failures the endpoint is responsible for
+		if r.Context().Err() == nil {
+			state.recordFailure(err.Error())
+		}
+		handleUpstreamError(w, r, upstreamCtx, "error sending request to LnxEndpoint", err)
+		return nil, false
 	}
 	defer func() {
 		err := lnxResp.Body.Close()
@@ -301,31 +597,31 @@ func Translate(w http.ResponseWriter, r *http.Request) {
 			logger.Error().Err(err).Msg("Error closing response body stream")
 		}
 	}()
-
-	// Set Header
-	w.Header().Set("Content-Type", lnxResp.Header["Content-Type"][0])
-	w.Header().Set("Access-Control-Allow-Origin", "*") // same as Google response
+	span.SetAttributes(attribute.Int("http.response.status_code", lnxResp.StatusCode))
 
 	// Handle non-OK responses
 	if lnxResp.StatusCode != http.StatusOK {
-		handleNonOKResponse(w, lnxResp)
-		return
+		if lnxResp.StatusCode >= http.StatusInternalServerError {
+			state.recordFailure(lnxResp.Status)
+		} else {
+			state.recordSuccess()
+		}
+		tracing.RecordError(span, fmt.Errorf("unexpected status %s", lnxResp.Status))
+		handleNonOKResponse(w, r, lnxResp)
+		return nil, false
 	}
 
-	// Set google format response body
-	lnxBody, err := io.ReadAll(io.LimitReader(lnxResp.Body, MaxResponseSize))
-	if err != nil {
-		handleInternalServerError(w, "Error reading LnxEndpoint response body", err)
-		return
-	}
-	body, err := translate.ToGoogleResponseBody(lnxBody, isAuto)
-	if err != nil {
-		handleInternalServerError(w, "Error converting to google response body", err)
-		return
-	}
-	w.WriteHeader(lnxResp.StatusCode)
-	_, err = w.Write(body)
+	lnxBody, err := readUpstreamBody(lnxResp)
+	observer.responseBytes(len(lnxBody))
 	if err != nil {
-		logger.Error().Err(err).Msg("Error writing response body for translate requests")
+		tracing.RecordError(span, err)
+		if errors.Is(err, errMalformedResponse) {
+			state.recordFailure(err.Error())
+			writeError(w, r, ErrUpstream, "Invalid LnxEndpoint response", err)
+			return nil, false
+		}
+		handleUpstreamError(w, r, upstreamCtx, "Error reading LnxEndpoint response body", err)
+		return nil, false
 	}
+	return lnxBody, true
 }
@@ -1,10 +1,18 @@
 package controller
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/brave/go-translate/config"
 	"github.com/brave/go-translate/language"
 )
 
@@ -98,4 +106,158 @@ func TestLnxEndpointConfiguration_GetEndpoint(t *testing.T) {
 			assert.Equal(t, expected, got)
 		}
 	})
+
+	t.Run("auto detect", func(t *testing.T) {
+		assert.Equal(t, "endpoint1.com", conf.GetEndpoint("auto", "it"))
+		assert.Equal(t, "endpoint2.com", conf.GetEndpoint("auto", "de"))
+		assert.Equal(t, "", conf.GetEndpoint("auto", "ja"))
+	})
+
+	t.Run("drained endpoint", func(t *testing.T) {
+		stateOf("endpoint1.com").drained.Store(true)
+		defer stateOf("endpoint1.com").drained.Store(false)
+
+		// pairs are not sent to endpoints which don't serve them
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, "endpoint2.com", conf.GetEndpoint("en", "es"))
+			assert.Equal(t, "", conf.GetEndpoint("en", "it"))
+		}
+	})
+}
+
+func setupTestEndpoint(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	lists := []language.GoogleLanguageList{
+		{
+			Sl: map[string]string{"en": "English", "es": "Spanish"},
+			Tl: map[string]string{"en": "English", "es": "Spanish"},
+		},
+	}
+	conf, err := NewLnxEndpointConfiguration([]string{ts.URL}, []float64{1}, lists)
+	assert.NoError(t, err)
+	SetLnxEndpoint(conf)
+	return ts
+}
+
+func newTranslateRequest(query string, q ...string) *http.Request {
+	form := url.Values{"q": q}
+	r := httptest.NewRequest(http.MethodPost, "/translate_a/t?"+query, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestTranslateUpstreamTimeout(t *testing.T) {
+	setupTestEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sourceText":["Hello"],"translatedText":["Hola"]}`))
+	})
+
+	timeout := UpstreamTimeout
+	UpstreamTimeout = 10 * time.Millisecond
+	defer func() { UpstreamTimeout = timeout }()
+
+	before := testutil.ToFloat64(upstreamCancelled.WithLabelValues(cancelReasonUpstream))
+	w := httptest.NewRecorder()
+	Translate(w, newTranslateRequest("sl=en&tl=es", "Hello"))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(upstreamCancelled.WithLabelValues(cancelReasonUpstream)))
+}
+
+func TestTranslateRequestTimeout(t *testing.T) {
+	setupTestEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sourceText":["Hello"],"translatedText":["Hola"]}`))
+	})
+
+	// the client timeout fires before the upstream deadline
+	transportConf := config.Default().Lnx.Transport
+	transportConf.RequestTimeout = 10 * time.Millisecond
+	SetTransportConfig(transportConf)
+	defer SetTransportConfig(config.Default().Lnx.Transport)
+
+	before := testutil.ToFloat64(upstreamCancelled.WithLabelValues(cancelReasonUpstream))
+	w := httptest.NewRecorder()
+	Translate(w, newTranslateRequest("sl=en&tl=es", "Hello"))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, ErrUpstreamTimeout, decodeError(t, w).Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(upstreamCancelled.WithLabelValues(cancelReasonUpstream)))
+}
+
+func TestTranslateSameLanguage(t *testing.T) {
+	called := false
+	setupTestEndpoint(t, func(_ http.ResponseWriter, _ *http.Request) {
+		called = true
+	})
+
+	w := httptest.NewRecorder()
+	Translate(w, newTranslateRequest("sl=en&tl=en", "Hello", "World"))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `["Hello","World"]`, w.Body.String())
+	assert.False(t, called)
+}
+
+func TestNewLnxEndpointConfigurationFromConfig(t *testing.T) {
+	list := language.GoogleLanguageList{
+		Sl: map[string]string{"en": "English", "es": "Spanish", "ja": "Japanese"},
+		Tl: map[string]string{"en": "English", "es": "Spanish", "ja": "Japanese"},
+	}
+	endpointConfs := []config.EndpointConfig{
+		{URL: "endpoint1.com", PairWeights: map[string]float64{"en:es": 3}, Block: []string{"*:ja"}},
+		{URL: "endpoint2.com", APIKey: "key2", Allow: []string{"en:*"}},
+	}
+
+	conf, err := NewLnxEndpointConfigurationFromConfig(endpointConfs, []language.GoogleLanguageList{list, list})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []float64{1, 1}, conf.DefaultWeights)
+	assert.Equal(t, map[string]float64{"endpoint1.com": 3, "endpoint2.com": 1}, conf.LanguagePairWeights["en"]["es"])
+	assert.Equal(t, map[string]float64{"endpoint2.com": 1}, conf.LanguagePairWeights["en"]["ja"])
+	assert.Equal(t, map[string]float64{"endpoint1.com": 1}, conf.LanguagePairWeights["es"]["en"])
+	assert.Empty(t, conf.LanguagePairWeights["es"]["ja"])
+	assert.Equal(t, "", conf.GetEndpoint("es", "ja"))
+	assert.Equal(t, "endpoint1.com", conf.GetEndpoint("auto", "es"))
+
+	key, err := conf.APIKey("endpoint1.com")
+	assert.NoError(t, err)
+	assert.Equal(t, config.Secret(""), key)
+	key, err = conf.APIKey("endpoint2.com")
+	assert.NoError(t, err)
+	assert.Equal(t, config.Secret("key2"), key)
+}
+
+func TestReloadLnxEndpoint(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api"+languagePath, r.URL.Path)
+		assert.Equal(t, "Bearer shared", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`[{"code_alpha_1": "en", "codeName": "English"}, {"code_alpha_1": "es", "codeName": "Spanish"}]`))
+	}))
+	defer ts.Close()
+
+	conf := config.LnxConfig{APIKey: "shared", Hosts: []string{ts.URL + "/api"}}
+	assert.NoError(t, ReloadLnxEndpoint(context.Background(), &conf))
+	current := CurrentLnxEndpoint()
+	assert.Equal(t, []string{ts.URL + "/api"}, current.Endpoints)
+	key, err := current.APIKey(ts.URL + "/api")
+	assert.NoError(t, err)
+	assert.Equal(t, config.Secret("shared"), key)
+	assert.Empty(t, conf.Endpoints)
+
+	// an unreachable endpoint is rejected and the current configuration kept
+	conf.Hosts = append(conf.Hosts, "http://127.0.0.1:1/api")
+	conf.Weights = []float64{1, 1}
+	assert.Error(t, ReloadLnxEndpoint(context.Background(), &conf))
+	assert.Same(t, current, CurrentLnxEndpoint())
 }
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/brave/go-translate/config"
+)
+
+// corsPolicy is the compiled form of a config.CORSConfig.
+type corsPolicy struct {
+	anyOrigin bool
+	origins   map[string]bool
+	// subdomain patterns, split around their wildcard
+	patterns [][2]string
+	methods  map[string]bool
+	headers  map[string]bool
+
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
+}
+
+func newCORSPolicy(conf *config.CORSConfig) *corsPolicy {
+	p := &corsPolicy{
+		origins:       make(map[string]bool),
+		methods:       make(map[string]bool),
+		headers:       make(map[string]bool),
+		allowMethods:  strings.Join(conf.AllowedMethods, ", "),
+		allowHeaders:  strings.Join(conf.AllowedHeaders, ", "),
+		exposeHeaders: strings.Join(conf.ExposedHeaders, ", "),
+		maxAge:        strconv.Itoa(int(conf.MaxAge.Seconds())),
+	}
+	for _, origin := range conf.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == config.CORSAnyOrigin:
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			p.patterns = append(p.patterns, [2]string{prefix, suffix})
+		default:
+			p.origins[origin] = true
+		}
+	}
+	for _, method := range conf.AllowedMethods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range conf.AllowedHeaders {
+		p.headers[strings.ToLower(header)] = true
+	}
+	return p
+}
+
+// allowOrigin reports whether cross-origin requests from origin are allowed.
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		prefix, suffix := pattern[0], pattern[1]
+		if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			!strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:@?#") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowPreflight reports whether the method and headers a preflight request
+// asks for are allowed.
+func (p *corsPolicy) allowPreflight(r *http.Request) bool {
+	if !p.methods[r.Header.Get("Access-Control-Request-Method")] {
+		return false
+	}
+	for header := range strings.SplitSeq(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if len(header) > 0 && !p.headers[header] {
+			return false
+		}
+	}
+	return true
+}
+
+// allowOriginValue returns the Access-Control-Allow-Origin value for origin.
+func (p *corsPolicy) allowOriginValue(origin string) string {
+	if p.anyOrigin {
+		return config.CORSAnyOrigin
+	}
+	return origin
+}
+
+// CORS applies the cross-origin resource sharing policy to every request of
+// the API server, and answers preflight requests itself. Requests from origins
+// which are not allowed are served without CORS headers, so that browsers
+// don't expose the response.
+func CORS(conf *config.CORSConfig) func(http.Handler) http.Handler {
+	p := newCORSPolicy(conf)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			if !p.anyOrigin {
+				// the response depends on the origin, caches must not share it
+				header.Add("Vary", "Origin")
+			}
+			origin := r.Header.Get("Origin")
+			if len(origin) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			allowed := p.allowOrigin(origin)
+
+			if r.Method == http.MethodOptions && len(r.Header.Get("Access-Control-Request-Method")) > 0 {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+				if allowed && p.allowPreflight(r) {
+					header.Set("Access-Control-Allow-Origin", p.allowOriginValue(origin))
+					header.Set("Access-Control-Allow-Methods", p.allowMethods)
+					if len(p.allowHeaders) > 0 {
+						header.Set("Access-Control-Allow-Headers", p.allowHeaders)
+					}
+					header.Set("Access-Control-Max-Age", p.maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if allowed {
+				header.Set("Access-Control-Allow-Origin", p.allowOriginValue(origin))
+				if len(p.exposeHeaders) > 0 {
+					header.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
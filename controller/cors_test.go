@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/config"
+)
+
+func serveCORS(origins []string, r *http.Request) *httptest.ResponseRecorder {
+	conf := config.Default().CORS
+	conf.AllowedOrigins = origins
+	handler := CORS(&conf)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func newPreflightRequest(origin, method, headers string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, "/translate_a/t", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if len(headers) > 0 {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+func TestCORSAllowlist(t *testing.T) {
+	origins := []string{"https://translate.example.com", "https://*.brave.com"}
+
+	for origin, allowed := range map[string]bool{
+		"https://translate.example.com": true,
+		"https://TRANSLATE.example.com": true,
+		"https://search.brave.com":      true,
+		"https://a.b.brave.com":         true,
+		"https://brave.com":             false,
+		"http://search.brave.com":       false,
+		"https://evil.com":              false,
+		"https://evil.com/.brave.com":   false,
+	} {
+		r := newTranslateRequest("sl=en&tl=es", "Hello")
+		r.Header.Set("Origin", origin)
+		w := serveCORS(origins, r)
+		assert.Equal(t, http.StatusOK, w.Code, origin)
+		assert.Equal(t, []string{"Origin"}, w.Header().Values("Vary"), origin)
+		if allowed {
+			assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"), origin)
+			assert.Equal(t, "Retry-After, X-Request-Id", w.Header().Get("Access-Control-Expose-Headers"), origin)
+		} else {
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	}
+
+	// same-origin requests don't carry an Origin header
+	w := serveCORS(origins, newTranslateRequest("sl=en&tl=es", "Hello"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSPreflight(t *testing.T) {
+	origins := []string{"https://translate.example.com"}
+
+	w := serveCORS(origins, newPreflightRequest("https://translate.example.com", http.MethodPost, "content-type, authorization"))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://translate.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, HEAD, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization, Content-Type, X-Request-Id", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+
+	// preflights which are not allowed are answered without CORS headers
+	for _, r := range []*http.Request{
+		newPreflightRequest("https://evil.com", http.MethodPost, ""),
+		newPreflightRequest("https://translate.example.com", http.MethodDelete, ""),
+		newPreflightRequest("https://translate.example.com", http.MethodPost, "X-Custom"),
+	} {
+		w := serveCORS(origins, r)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	}
+
+	// other OPTIONS requests are not preflights
+	r := httptest.NewRequest(http.MethodOptions, "/translate_a/t", nil)
+	r.Header.Set("Origin", "https://translate.example.com")
+	assert.Equal(t, http.StatusOK, serveCORS(origins, r).Code)
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	r := newTranslateRequest("sl=en&tl=es", "Hello")
+	r.Header.Set("Origin", "https://anywhere.example")
+	w := serveCORS([]string{config.CORSAnyOrigin}, r)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Values("Vary"))
+
+	w = serveCORS([]string{config.CORSAnyOrigin}, newPreflightRequest("https://anywhere.example", http.MethodGet, ""))
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSDisabled(t *testing.T) {
+	r := newTranslateRequest("sl=en&tl=es", "Hello")
+	r.Header.Set("Origin", "https://translate.example.com")
+	w := serveCORS(nil, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
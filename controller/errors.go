@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/brave-intl/bat-go/libs/logging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxLoggedUpstreamBody is the number of bytes of an upstream error response
+// body which are logged. Upstream bodies are never sent to clients.
+const maxLoggedUpstreamBody = 512
+
+// ErrorCode classifies an error returned to clients.
+type ErrorCode string
+
+// Error codes returned to clients.
+const (
+	// ErrBadRequest is returned for malformed requests.
+	ErrBadRequest ErrorCode = "bad_request"
+	// ErrRequestTooLarge is returned for requests exceeding a size limit.
+	ErrRequestTooLarge ErrorCode = "request_too_large"
+	// ErrUnsupportedPair is returned for well-formed requests for a language
+	// pair which no endpoint serves.
+	ErrUnsupportedPair ErrorCode = "unsupported_language_pair"
+	// ErrUnauthorized is returned for requests without valid credentials.
+	ErrUnauthorized ErrorCode = "unauthorized"
+	// ErrNotFound is returned for requests about an unknown resource.
+	ErrNotFound ErrorCode = "not_found"
+	// ErrRateLimited is returned when the client exhausted its rate limit.
+	ErrRateLimited ErrorCode = "rate_limited"
+	// ErrUpstream is returned when Lingvanex failed or returned an invalid response.
+	ErrUpstream ErrorCode = "upstream_error"
+	// ErrUpstreamRejected is returned when Lingvanex rejected the request.
+	ErrUpstreamRejected ErrorCode = "upstream_rejected"
+	// ErrUnavailable is returned when no endpoint can currently take the request.
+	ErrUnavailable ErrorCode = "unavailable"
+	// ErrUpstreamTimeout is returned when Lingvanex did not respond in time.
+	ErrUpstreamTimeout ErrorCode = "upstream_timeout"
+	// ErrInternal is returned for unexpected failures of the service itself.
+	ErrInternal ErrorCode = "internal_error"
+)
+
+var errorStatus = map[ErrorCode]int{
+	ErrBadRequest:       http.StatusBadRequest,
+	ErrRequestTooLarge:  http.StatusRequestEntityTooLarge,
+	ErrUnsupportedPair:  http.StatusUnprocessableEntity,
+	ErrUnauthorized:     http.StatusUnauthorized,
+	ErrNotFound:         http.StatusNotFound,
+	ErrRateLimited:      http.StatusTooManyRequests,
+	ErrUpstream:         http.StatusBadGateway,
+	ErrUpstreamRejected: http.StatusBadGateway,
+	ErrUnavailable:      http.StatusServiceUnavailable,
+	ErrUpstreamTimeout:  http.StatusGatewayTimeout,
+	ErrInternal:         http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status code of responses with the error code.
+func (c ErrorCode) Status() int {
+	if status, ok := errorStatus[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Retryable reports whether the same request may succeed if sent again later.
+func (c ErrorCode) Retryable() bool {
+	switch c {
+	case ErrRateLimited, ErrUpstream, ErrUnavailable, ErrUpstreamTimeout:
+		return true
+	}
+	return false
+}
+
+// ErrorResponse is the JSON body of error responses.
+type ErrorResponse struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Retryable bool      `json:"retryable"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// writeError writes an error response with the given code. The details of err
+// are only included in the message of client errors, server errors are logged
+// instead so that internal details don't leak to clients.
+func writeError(w http.ResponseWriter, r *http.Request, code ErrorCode, message string, err error) {
+	status := code.Status()
+	logger := logging.FromContext(r.Context())
+	if status >= http.StatusInternalServerError {
+		logger.Error().Err(err).Str("code", string(code)).Msg(message)
+	} else if err != nil {
+		message = fmt.Sprintf("%s: %v", message, err)
+	}
+
+	body, _ := json.Marshal(ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Retryable: code.Retryable(),
+		RequestID: requestID(r.Context()),
+	})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		logger.Error().Err(err).Msg("Error writing error response")
+	}
+}
+
+// handleBadRequestError writes a 400 Bad Request error response, or 413 if the
+// request body exceeded its size limit.
+func handleBadRequestError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, r, ErrRequestTooLarge, message, err)
+		return
+	}
+	writeError(w, r, ErrBadRequest, message, err)
+}
+
+// handleInternalServerError writes a 500 Internal Server Error response
+func handleInternalServerError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	writeError(w, r, ErrInternal, message, err)
+}
+
+// cancellationReason returns why the upstream call using upstreamCtx was cut
+// short, or an empty string if it was not cancelled. Timeouts of the client or
+// transport, such as LNX_REQUEST_TIMEOUT, have no context deadline but count
+// as upstream timeouts too.
+func cancellationReason(r *http.Request, upstreamCtx context.Context, err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(r.Context().Err(), context.Canceled):
+		return cancelReasonClient
+	case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+		return cancelReasonServer
+	case errors.Is(upstreamCtx.Err(), context.DeadlineExceeded):
+		return cancelReasonUpstream
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return cancelReasonUpstream
+	}
+	return ""
+}
+
+// handleUpstreamError writes the error response for a failed upstream call and
+// records cancelled calls.
+func handleUpstreamError(w http.ResponseWriter, r *http.Request, upstreamCtx context.Context, message string, err error) {
+	reason := cancellationReason(r, upstreamCtx, err)
+	if reason == "" {
+		writeError(w, r, ErrUpstream, message, err)
+		return
+	}
+
+	upstreamCancelled.With(prometheus.Labels{"reason": reason}).Inc()
+	logging.FromContext(r.Context()).Warn().Err(err).Str("reason", reason).Msg("Upstream request cancelled")
+	if reason == cancelReasonUpstream {
+		writeError(w, r, ErrUpstreamTimeout, message, err)
+	}
+	// otherwise the client is gone or the router timeout already responded
+}
+
+// handleNonOKResponse handles responses with non-OK status codes. The start of
+// the upstream body is logged, clients only get the status.
+func handleNonOKResponse(w http.ResponseWriter, r *http.Request, lnxResp *http.Response) {
+	body, err := io.ReadAll(io.LimitReader(lnxResp.Body, maxLoggedUpstreamBody))
+	logger := logging.FromContext(r.Context())
+	if err != nil {
+		logger.Warn().Err(err).Msg("Error reading LnxEndpoint error response body")
+	}
+	logger.Warn().
+		Int("status", lnxResp.StatusCode).
+		Bytes("body", body).
+		Msg("LnxEndpoint error response")
+
+	code := ErrUpstream
+	if lnxResp.StatusCode < http.StatusInternalServerError && lnxResp.StatusCode != http.StatusTooManyRequests {
+		code = ErrUpstreamRejected
+	}
+	writeError(w, r, code, fmt.Sprintf("LnxEndpoint responded with status %d", lnxResp.StatusCode), nil)
+}
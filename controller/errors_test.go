@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/config"
+	"github.com/brave/go-translate/language"
+)
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) ErrorResponse {
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	var resp ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestWriteError(t *testing.T) {
+	r := withRequestID(httptest.NewRequest(http.MethodPost, "/translate_a/t", nil), "host/abc-000003")
+
+	w := httptest.NewRecorder()
+	handleBadRequestError(w, r, "invalid request", errors.New("missing tl"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ErrorResponse{
+		Code:      ErrBadRequest,
+		Message:   "invalid request: missing tl",
+		RequestID: "host/abc-000003",
+	}, decodeError(t, w))
+
+	// details of server errors are not sent to clients
+	w = httptest.NewRecorder()
+	handleInternalServerError(w, r, "error reading credentials", errors.New("open /etc/lnx/api-key: permission denied"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	resp := decodeError(t, w)
+	assert.Equal(t, ErrInternal, resp.Code)
+	assert.NotContains(t, resp.Message, "/etc/lnx")
+
+	w = httptest.NewRecorder()
+	handleBadRequestError(w, r, "invalid request", &http.MaxBytesError{Limit: 10})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, ErrRequestTooLarge, decodeError(t, w).Code)
+}
+
+func TestTranslateUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		code      ErrorCode
+		retryable bool
+	}{
+		{"server error", http.StatusInternalServerError, ErrUpstream, true},
+		{"rate limited", http.StatusTooManyRequests, ErrUpstream, true},
+		{"rejected", http.StatusForbidden, ErrUpstreamRejected, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestEndpoint(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("internal stack trace"))
+			})
+
+			w := httptest.NewRecorder()
+			Translate(w, newTranslateRequest("sl=en&tl=es", "Hello"))
+
+			assert.Equal(t, http.StatusBadGateway, w.Code)
+			assert.NotContains(t, w.Body.String(), "stack trace")
+			resp := decodeError(t, w)
+			assert.Equal(t, tt.code, resp.Code)
+			assert.Equal(t, tt.retryable, resp.Retryable)
+		})
+	}
+}
+
+func TestTranslateUnsupportedPair(t *testing.T) {
+	list := language.GoogleLanguageList{
+		Sl: map[string]string{"en": "English", "ja": "Japanese"},
+		Tl: map[string]string{"en": "English", "ja": "Japanese"},
+	}
+	conf, err := NewLnxEndpointConfigurationFromConfig(
+		[]config.EndpointConfig{{URL: "http://endpoint1.com", Block: []string{"*:ja"}}},
+		[]language.GoogleLanguageList{list},
+	)
+	assert.NoError(t, err)
+	SetLnxEndpoint(conf)
+
+	w := httptest.NewRecorder()
+	Translate(w, newTranslateRequest("sl=en&tl=ja", "Hello"))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	resp := decodeError(t, w)
+	assert.Equal(t, ErrUnsupportedPair, resp.Code)
+	assert.False(t, resp.Retryable)
+	assert.Contains(t, resp.Message, "en:ja")
+}
+
+func TestTranslateUnlistedPair(t *testing.T) {
+	called := false
+	setupTestEndpoint(t, func(_ http.ResponseWriter, _ *http.Request) {
+		called = true
+	})
+
+	// no endpoint lists Japanese
+	for _, query := range []string{"sl=en&tl=ja", "sl=auto&tl=ja"} {
+		w := httptest.NewRecorder()
+		Translate(w, newTranslateRequest(query, "Hello"))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, query)
+		assert.Equal(t, ErrUnsupportedPair, decodeError(t, w).Code, query)
+	}
+	assert.False(t, called)
+}
+
+func TestTranslateMalformedUpstreamResponse(t *testing.T) {
+	valid := `{"sourceText":["Hello"],"translatedText":["Hola"]}`
+	tests := []struct {
+		name        string
+		contentType []string
+		body        string
+		status      int
+	}{
+		{"missing content type", nil, valid, http.StatusOK},
+		{"json with charset", []string{"application/json; charset=utf-8"}, valid, http.StatusOK},
+		{"html", []string{"text/html"}, "<html>proxy error</html>", http.StatusBadGateway},
+		{"unexpected content type", []string{"text/plain"}, valid, http.StatusOK},
+		{"empty body", []string{"application/json"}, "", http.StatusBadGateway},
+		{"truncated json", []string{"application/json"}, valid[:20], http.StatusBadGateway},
+		{"wrong shape", []string{"application/json"}, `[1, 2]`, http.StatusBadGateway},
+		{"empty object", []string{"application/json"}, `{}`, http.StatusBadGateway},
+		{"null", []string{"application/json"}, `null`, http.StatusBadGateway},
+		{"no segments", []string{"application/json"}, `{"sourceText":[],"translatedText":[]}`, http.StatusBadGateway},
+		{"too many segments", []string{"application/json"}, `{"sourceText":["Hello"],"translatedText":["Hola","Hola"]}`, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := setupTestEndpoint(t, func(w http.ResponseWriter, _ *http.Request) {
+				// a nil value keeps net/http from sniffing a Content-Type
+				w.Header()["Content-Type"] = tt.contentType
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			w := httptest.NewRecorder()
+			Translate(w, newTranslateRequest("sl=en&tl=es", "Hello"))
+
+			assert.Equal(t, tt.status, w.Code)
+			health := stateOf(ts.URL).health()
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), "Hola")
+				assert.Equal(t, healthHealthy, health.Status)
+				return
+			}
+			assert.Equal(t, ErrUpstream, decodeError(t, w).Code)
+			assert.Equal(t, 1, health.ConsecutiveFailures)
+			assert.Contains(t, health.LastError, errMalformedResponse.Error())
+			assert.NotContains(t, w.Body.String(), "proxy error")
+		})
+	}
+}
+
+func TestTranslateUpstreamResponseTooLarge(t *testing.T) {
+	setupTestEndpoint(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sourceText":["Hello"],"translatedText":["Hola"]}`))
+	})
+
+	maxSize := MaxResponseSize
+	MaxResponseSize = 16
+	defer func() { MaxResponseSize = maxSize }()
+
+	w := httptest.NewRecorder()
+	Translate(w, newTranslateRequest("sl=en&tl=es", "Hello"))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, ErrUpstream, decodeError(t, w).Code)
+}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Paths of the health endpoints, meant for Kubernetes probes.
+const (
+	LivenessPath  = "/health/live"
+	ReadinessPath = "/health/ready"
+)
+
+// unhealthyExpiry is how long an unhealthy endpoint keeps the service from
+// being ready without a new failure. Health is observed from requests only,
+// so readiness must not stay down for good once probes removed all traffic.
+const unhealthyExpiry = 30 * time.Second
+
+// Overall health states.
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+var (
+	// ready reports whether the service should receive traffic. It is false
+	// until the server started and once it is shutting down.
+	ready atomic.Bool
+	// lastReload is the outcome of the latest endpoint configuration reload.
+	lastReload atomic.Pointer[reloadStatus]
+)
+
+type reloadStatus struct {
+	at  time.Time
+	err error
+}
+
+// HealthResponse is the body of the liveness and readiness endpoints. They
+// are served without authentication, so endpoints are only identified by
+// their index in the configuration: their URLs and errors are left to the
+// admin API.
+type HealthResponse struct {
+	Status    string              `json:"status"`
+	Reason    string              `json:"reason,omitempty"`
+	Endpoints []EndpointReadiness `json:"endpoints,omitempty"`
+}
+
+// EndpointReadiness describes whether an endpoint receives requests.
+type EndpointReadiness struct {
+	Index     int    `json:"index"`
+	Drained   bool   `json:"drained"`
+	Status    string `json:"status"`
+	Available bool   `json:"available"`
+}
+
+// HealthDetails is the body of the admin health endpoint, the readiness of
+// the service along with the state of the configuration and of each endpoint.
+type HealthDetails struct {
+	Status    string           `json:"status"`
+	Reason    string           `json:"reason,omitempty"`
+	Config    ConfigHealth     `json:"config"`
+	Endpoints []EndpointStatus `json:"endpoints"`
+}
+
+// ConfigHealth describes the state of the endpoint configuration.
+type ConfigHealth struct {
+	Loaded          bool       `json:"loaded"`
+	LastReload      *time.Time `json:"last_reload,omitempty"`
+	LastReloadError string     `json:"last_reload_error,omitempty"`
+}
+
+// EndpointStatus describes the runtime state of an endpoint.
+type EndpointStatus struct {
+	URL      string         `json:"url"`
+	Drained  bool           `json:"drained"`
+	InFlight int64          `json:"in_flight"`
+	Health   EndpointHealth `json:"health"`
+}
+
+// SetReady marks whether the service should receive traffic.
+func SetReady(v bool) {
+	ready.Store(v)
+}
+
+// Ready reports whether the service should receive traffic.
+func Ready() bool {
+	return ready.Load()
+}
+
+// recordReload records the outcome of an endpoint configuration reload.
+func recordReload(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	endpointReloads.With(prometheus.Labels{"result": result}).Inc()
+	lastReload.Store(&reloadStatus{at: time.Now(), err: err})
+}
+
+// HealthChecks serves the liveness and readiness endpoints. It is meant to be
+// installed ahead of the logging and rate limiting middlewares, so that
+// probes are not logged nor limited.
+func HealthChecks(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			switch r.URL.Path {
+			case LivenessPath:
+				Liveness(w, r)
+				return
+			case ReadinessPath:
+				Readiness(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Liveness reports that the process is able to serve requests. It does not
+// depend on Lingvanex.
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, HealthResponse{Status: statusOK})
+}
+
+// Readiness reports whether the service should receive traffic: it must not
+// be starting or shutting down, the endpoint configuration must be loaded and
+// at least one endpoint must be neither drained nor unhealthy.
+func Readiness(w http.ResponseWriter, r *http.Request) {
+	details := healthDetails()
+	health := HealthResponse{Status: details.Status, Reason: details.Reason}
+	for i, endpoint := range details.Endpoints {
+		health.Endpoints = append(health.Endpoints, EndpointReadiness{
+			Index:     i,
+			Drained:   endpoint.Drained,
+			Status:    endpoint.Health.Status,
+			Available: endpoint.available(),
+		})
+	}
+	writeHealth(w, r, health)
+}
+
+// GetHealthDetails writes the readiness of the service with the state of the
+// configuration and of each endpoint, for the admin API.
+func GetHealthDetails(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, r, healthDetails())
+}
+
+func healthDetails() HealthDetails {
+	details := HealthDetails{Status: statusOK, Endpoints: []EndpointStatus{}}
+	if reload := lastReload.Load(); reload != nil {
+		details.Config.LastReload = &reload.at
+		if reload.err != nil {
+			details.Config.LastReloadError = reload.err.Error()
+		}
+	}
+
+	if conf := CurrentLnxEndpoint(); conf != nil {
+		details.Config.Loaded = true
+		for _, endpoint := range conf.Endpoints {
+			state := stateOf(endpoint)
+			details.Endpoints = append(details.Endpoints, EndpointStatus{
+				URL:      endpoint,
+				Drained:  state.drained.Load(),
+				InFlight: state.inFlight.Load(),
+				Health:   state.health(),
+			})
+		}
+	}
+
+	switch {
+	case !Ready():
+		details.Status, details.Reason = statusUnavailable, "not serving"
+	case !details.Config.Loaded:
+		details.Status, details.Reason = statusUnavailable, "endpoint configuration not loaded"
+	case !slices.ContainsFunc(details.Endpoints, EndpointStatus.available):
+		details.Status, details.Reason = statusUnavailable, "no healthy endpoint"
+	}
+	return details
+}
+
+// available reports whether the endpoint may receive requests, i.e. it is
+// neither drained nor recently unhealthy.
+func (e EndpointStatus) available() bool {
+	if e.Drained {
+		return false
+	}
+	return e.Health.Status != healthUnhealthy || time.Since(*e.Health.LastFailure) > unhealthyExpiry
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, health HealthResponse) {
+	status := http.StatusOK
+	if health.Status != statusOK {
+		status = http.StatusServiceUnavailable
+	}
+	body, err := json.Marshal(health)
+	if err != nil {
+		handleInternalServerError(w, r, "error marshalling health", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/config"
+	"github.com/brave/go-translate/language"
+)
+
+func getHealth(t *testing.T, path string) (int, HealthResponse) {
+	handler := HealthChecks(http.NotFoundHandler())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	// nothing about the endpoints but their index and state is public
+	assert.NotContains(t, w.Body.String(), "http://health-")
+	assert.NotContains(t, w.Body.String(), "connection refused")
+	var health HealthResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	return w.Code, health
+}
+
+func TestHealthChecks(t *testing.T) {
+	list := language.GoogleLanguageList{
+		Sl: map[string]string{"en": "English"},
+		Tl: map[string]string{"es": "Spanish"},
+	}
+	conf, err := NewLnxEndpointConfiguration([]string{"http://health-a", "http://health-b"}, []float64{1, 1}, []language.GoogleLanguageList{list, list})
+	assert.NoError(t, err)
+	SetLnxEndpoint(conf)
+	SetReady(true)
+	defer SetReady(false)
+
+	code, health := getHealth(t, ReadinessPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, statusOK, health.Status)
+	assert.Equal(t, []EndpointReadiness{
+		{Index: 0, Status: healthUnknown, Available: true},
+		{Index: 1, Status: healthUnknown, Available: true},
+	}, health.Endpoints)
+
+	// endpoints which are drained or failing don't count
+	stateOf("http://health-a").drained.Store(true)
+	defer stateOf("http://health-a").drained.Store(false)
+	for range unhealthyThreshold {
+		stateOf("http://health-b").recordFailure("connection refused")
+	}
+	defer stateOf("http://health-b").recordSuccess()
+
+	code, health = getHealth(t, ReadinessPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "no healthy endpoint", health.Reason)
+	assert.Equal(t, []EndpointReadiness{
+		{Index: 0, Drained: true, Status: healthUnknown},
+		{Index: 1, Status: healthUnhealthy},
+	}, health.Endpoints)
+
+	// liveness does not depend on the endpoints
+	code, health = getHealth(t, LivenessPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthResponse{Status: statusOK}, health)
+
+	// the details are left to the admin API
+	w := adminRequest(AdminRouter(&config.AdminConfig{Token: "admin-token"}), http.MethodGet, "/admin/health", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var details HealthDetails
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+	assert.Equal(t, statusUnavailable, details.Status)
+	assert.True(t, details.Config.Loaded)
+	assert.Equal(t, "http://health-b", details.Endpoints[1].URL)
+	assert.Equal(t, healthUnhealthy, details.Endpoints[1].Health.Status)
+	assert.Equal(t, "connection refused", details.Endpoints[1].Health.LastError)
+
+	// failures expire so that readiness doesn't stay down without traffic
+	state := stateOf("http://health-b")
+	state.mu.Lock()
+	state.lastFailure = time.Now().Add(-2 * unhealthyExpiry)
+	state.mu.Unlock()
+	code, _ = getHealth(t, ReadinessPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	recordReload(errors.New("failed to get language list"))
+	SetReady(false)
+	code, health = getHealth(t, ReadinessPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not serving", health.Reason)
+	assert.Equal(t, "failed to get language list", healthDetails().Config.LastReloadError)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/brave/go-translate/config"
+)
+
+// Request size limits, used as metric label.
+const (
+	limitBodyBytes    = "body_bytes"
+	limitSegments     = "segments"
+	limitSegmentChars = "segment_chars"
+	limitTotalChars   = "total_chars"
+)
+
+var (
+	oversizedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_oversized_requests_total",
+		Help: "The total number of translate requests rejected for exceeding a size limit, by limit",
+	},
+		[]string{"limit"},
+	)
+	requestSegments = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "translate_request_segments",
+		Help:    "The number of segments of translate requests within the size limits",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 7),
+	})
+	requestChars = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "translate_request_chars",
+		Help:    "The number of characters of translate requests within the size limits",
+		Buckets: prometheus.ExponentialBuckets(16, 4, 8),
+	})
+)
+
+// LimitRequestSize rejects translate requests exceeding one of the size
+// limits with 413 Request Entity Too Large, before they are parsed further or
+// sent to Lingvanex. Malformed bodies are rejected with 400 Bad Request.
+func LimitRequestSize(conf *config.LimitsConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if conf.MaxBodyBytes > 0 {
+				if r.ContentLength > conf.MaxBodyBytes {
+					rejectOversized(w, r, limitBodyBytes,
+						fmt.Errorf("body of %d bytes exceeds the limit of %d", r.ContentLength, conf.MaxBodyBytes))
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, conf.MaxBodyBytes)
+			}
+			if err := r.ParseForm(); err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					oversizedRequests.With(prometheus.Labels{"limit": limitBodyBytes}).Inc()
+				}
+				handleBadRequestError(w, r, "error parsing translate request", err)
+				return
+			}
+
+			segments := r.PostForm["q"]
+			if conf.MaxSegments > 0 && len(segments) > conf.MaxSegments {
+				rejectOversized(w, r, limitSegments,
+					fmt.Errorf("%d segments exceed the limit of %d", len(segments), conf.MaxSegments))
+				return
+			}
+			total := 0
+			for i, segment := range segments {
+				chars := utf8.RuneCountInString(segment)
+				if conf.MaxSegmentChars > 0 && chars > conf.MaxSegmentChars {
+					rejectOversized(w, r, limitSegmentChars,
+						fmt.Errorf("segment %d of %d characters exceeds the limit of %d", i, chars, conf.MaxSegmentChars))
+					return
+				}
+				total += chars
+			}
+			if conf.MaxTotalChars > 0 && total > conf.MaxTotalChars {
+				rejectOversized(w, r, limitTotalChars,
+					fmt.Errorf("%d characters exceed the limit of %d", total, conf.MaxTotalChars))
+				return
+			}
+
+			requestSegments.Observe(float64(len(segments)))
+			requestChars.Observe(float64(total))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rejectOversized writes a 413 response for a request exceeding limit.
+func rejectOversized(w http.ResponseWriter, r *http.Request, limit string, err error) {
+	oversizedRequests.With(prometheus.Labels{"limit": limit}).Inc()
+	writeError(w, r, ErrRequestTooLarge, "request too large", err)
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/config"
+)
+
+func serveLimited(conf config.LimitsConfig, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	served := false
+	handler := LimitRequestSize(&conf)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		served = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, served
+}
+
+func TestLimitRequestSize(t *testing.T) {
+	conf := config.LimitsConfig{MaxBodyBytes: 64, MaxSegments: 2, MaxSegmentChars: 5, MaxTotalChars: 8}
+
+	for name, test := range map[string]struct {
+		segments []string
+		limit    string
+	}{
+		"within limits": {segments: []string{"Hällo", "Wöw"}, limit: ""},
+		"body bytes":    {segments: []string{strings.Repeat("a", 100)}, limit: limitBodyBytes},
+		"segments":      {segments: []string{"a", "b", "c"}, limit: limitSegments},
+		"segment chars": {segments: []string{"Hello!"}, limit: limitSegmentChars},
+		"total chars":   {segments: []string{"Hello", "World"}, limit: limitTotalChars},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if len(test.limit) == 0 {
+				w, served := serveLimited(conf, newTranslateRequest("sl=en&tl=es", test.segments...))
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.True(t, served)
+				return
+			}
+
+			before := testutil.ToFloat64(oversizedRequests.WithLabelValues(test.limit))
+			w, served := serveLimited(conf, newTranslateRequest("sl=en&tl=es", test.segments...))
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+			assert.False(t, served)
+			assert.Equal(t, ErrRequestTooLarge, decodeError(t, w).Code)
+			assert.Equal(t, before+1, testutil.ToFloat64(oversizedRequests.WithLabelValues(test.limit)))
+		})
+	}
+}
+
+func TestLimitRequestSizeUnknownLength(t *testing.T) {
+	r := newTranslateRequest("sl=en&tl=es", strings.Repeat("a", 100))
+	// chunked bodies are cut off while reading
+	r.ContentLength = -1
+
+	before := testutil.ToFloat64(oversizedRequests.WithLabelValues(limitBodyBytes))
+	w, served := serveLimited(config.LimitsConfig{MaxBodyBytes: 64}, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.False(t, served)
+	assert.Equal(t, before+1, testutil.ToFloat64(oversizedRequests.WithLabelValues(limitBodyBytes)))
+}
+
+func TestLimitRequestSizeMalformed(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/translate_a/t?sl=en&tl=es", strings.NewReader("q=%zz"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w, served := serveLimited(config.Default().Limits, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, served)
+	assert.Equal(t, ErrBadRequest, decodeError(t, w).Code)
+}
+
+func TestLimitRequestSizeDisabled(t *testing.T) {
+	w, served := serveLimited(config.LimitsConfig{}, newTranslateRequest("sl=en&tl=es", strings.Repeat("a", 20000)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, served)
+}
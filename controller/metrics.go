@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Operations sent to Lingvanex, used as metric label.
+const (
+	operationTranslate = "translate"
+	operationLanguages = "languages"
+)
+
+var (
+	// UpstreamPairLabels enables the from_lang and to_lang labels of the upstream
+	// latency and response metrics. They are left empty otherwise, to limit the
+	// number of time series.
+	UpstreamPairLabels = false
+
+	upstreamLabels = []string{"endpoint", "operation", "from_lang", "to_lang"}
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "translate_upstream_request_duration_seconds",
+		Help:    "The time until Lingvanex responded with headers, by endpoint",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	},
+		upstreamLabels,
+	)
+	upstreamResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_responses_total",
+		Help: "The total number of Lingvanex responses by endpoint and status code, or code error if no response was received",
+	},
+		[]string{"endpoint", "operation", "from_lang", "to_lang", "code"},
+	)
+	upstreamTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_timeouts_total",
+		Help: "The total number of Lingvanex requests which timed out, by endpoint",
+	},
+		[]string{"endpoint", "operation"},
+	)
+	upstreamRequestBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_request_bytes_total",
+		Help: "The total number of request body bytes sent to Lingvanex, by endpoint",
+	},
+		[]string{"endpoint", "operation"},
+	)
+	upstreamResponseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_response_bytes_total",
+		Help: "The total number of response body bytes received from Lingvanex, by endpoint",
+	},
+		[]string{"endpoint", "operation"},
+	)
+	upstreamInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "translate_upstream_in_flight_requests",
+		Help: "The number of requests currently sent to Lingvanex, by endpoint",
+	},
+		[]string{"endpoint", "operation"},
+	)
+)
+
+// upstreamObserver records the metrics of a single request to Lingvanex.
+type upstreamObserver struct {
+	endpoint  string
+	operation string
+	from      string
+	to        string
+	start     time.Time
+}
+
+// observeUpstream starts recording the metrics of req, sent to endpoint for
+// the given language pair. The caller must call done once a response or error
+// was received.
+func observeUpstream(req *http.Request, endpoint, operation, from, to string) *upstreamObserver {
+	if !UpstreamPairLabels {
+		from, to = "", ""
+	}
+	o := &upstreamObserver{endpoint: endpoint, operation: operation, from: from, to: to, start: time.Now()}
+
+	labels := prometheus.Labels{"endpoint": endpoint, "operation": operation}
+	upstreamInFlight.With(labels).Inc()
+	if req.ContentLength > 0 {
+		upstreamRequestBytes.With(labels).Add(float64(req.ContentLength))
+	}
+	return o
+}
+
+// done records the outcome of client.Do.
+func (o *upstreamObserver) done(resp *http.Response, err error) {
+	labels := prometheus.Labels{"endpoint": o.endpoint, "operation": o.operation}
+	upstreamInFlight.With(labels).Dec()
+
+	pairLabels := prometheus.Labels{"endpoint": o.endpoint, "operation": o.operation, "from_lang": o.from, "to_lang": o.to}
+	upstreamDuration.With(pairLabels).Observe(time.Since(o.start).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	} else if isTimeout(err) {
+		upstreamTimeouts.With(labels).Inc()
+	}
+	pairLabels["code"] = code
+	upstreamResponses.With(pairLabels).Inc()
+}
+
+// responseBytes records the size of the response body read.
+func (o *upstreamObserver) responseBytes(n int) {
+	upstreamResponseBytes.With(prometheus.Labels{"endpoint": o.endpoint, "operation": o.operation}).Add(float64(n))
+}
+
+// isTimeout reports whether err is caused by a deadline or timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
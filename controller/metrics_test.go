@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateUpstreamMetrics(t *testing.T) {
+	lnxBody := `{"sourceText":["Hello"],"translatedText":["Hola"]}`
+	ts := setupTestEndpoint(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(lnxBody))
+	})
+
+	UpstreamPairLabels = true
+	defer func() { UpstreamPairLabels = false }()
+
+	for i := 0; i < 2; i++ {
+		Translate(httptest.NewRecorder(), newTranslateRequest("sl=en&tl=es", "Hello"))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(upstreamResponses.WithLabelValues(ts.URL, operationTranslate, "en", "es", "200")))
+	assert.Equal(t, float64(2*len(lnxBody)), testutil.ToFloat64(upstreamResponseBytes.WithLabelValues(ts.URL, operationTranslate)))
+	assert.Less(t, 0.0, testutil.ToFloat64(upstreamRequestBytes.WithLabelValues(ts.URL, operationTranslate)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(upstreamInFlight.WithLabelValues(ts.URL, operationTranslate)))
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brave-intl/bat-go/libs/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/brave/go-translate/auth"
+	"github.com/brave/go-translate/config"
+	"github.com/brave/go-translate/ratelimit"
+)
+
+// Limits enforced by the rate limiter, used as metric label.
+const (
+	limitRequests   = "requests"
+	limitCharacters = "characters"
+)
+
+// rateLimitPrefix prefixes the keys of the shared rate limiter backend.
+const rateLimitPrefix = "go-translate:ratelimit:"
+
+var (
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_rate_limited_requests_total",
+		Help: "The total number of translate requests rejected by the rate limiter, by exhausted limit",
+	},
+		[]string{"limit"},
+	)
+	rateLimitErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_rate_limit_errors_total",
+		Help: "The total number of rate limiter backend failures, requests are let through on failure",
+	},
+		[]string{"limit"},
+	)
+)
+
+// RateLimiter limits the requests and characters each client may send to
+// the translate endpoint.
+type RateLimiter struct {
+	key        config.RateLimitKey
+	requests   ratelimit.Limiter
+	characters ratelimit.Limiter
+}
+
+// NewRateLimiter returns a rate limiter for the configuration, or nil if no
+// limit is configured.
+func NewRateLimiter(conf *config.RateLimitConfig) (*RateLimiter, error) {
+	if !conf.Enabled() {
+		return nil, nil
+	}
+
+	newLimiter := func(name string, limit int) ratelimit.Limiter {
+		return ratelimit.NewMemory(ratelimit.Bucket{Limit: limit, Window: conf.Window})
+	}
+	if conf.Backend == config.RateLimitRedis {
+		opts, err := redis.ParseURL(string(conf.RedisURL))
+		if err != nil {
+			// the URL may hold a password
+			return nil, errors.New("invalid rate_limit.redis_url")
+		}
+		client := redis.NewClient(opts)
+		newLimiter = func(name string, limit int) ratelimit.Limiter {
+			return ratelimit.NewRedis(client, rateLimitPrefix+name+":", ratelimit.Bucket{Limit: limit, Window: conf.Window})
+		}
+	}
+
+	l := &RateLimiter{key: conf.Key}
+	if conf.Requests > 0 {
+		l.requests = newLimiter(limitRequests, conf.Requests)
+	}
+	if conf.Characters > 0 {
+		l.characters = newLimiter(limitCharacters, conf.Characters)
+	}
+	return l, nil
+}
+
+// Middleware rejects requests of clients which exhausted one of their limits
+// with 429 Too Many Requests.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.clientKey(r)
+
+		if !l.allow(w, r, l.requests, limitRequests, key, 1) {
+			return
+		}
+		if l.characters != nil {
+			// malformed requests are rejected by the handler
+			if err := r.ParseForm(); err == nil {
+				chars := 0
+				for _, q := range r.PostForm["q"] {
+					chars += utf8.RuneCountInString(q)
+				}
+				if !l.allow(w, r, l.characters, limitCharacters, key, chars) {
+					// rejected requests don't count against the request limit
+					l.refund(r, l.requests, limitRequests, key, 1)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes n tokens from the limiter, or writes a 429 response and returns
+// false if they are not available. Requests are let through if the limiter
+// fails, so that an outage of the shared backend doesn't take the service down.
+func (l *RateLimiter) allow(w http.ResponseWriter, r *http.Request, limiter ratelimit.Limiter, limit, key string, n int) bool {
+	if limiter == nil || n == 0 {
+		return true
+	}
+
+	ok, retryAfter, err := limiter.Allow(r.Context(), key, n)
+	if err != nil {
+		rateLimitErrors.With(prometheus.Labels{"limit": limit}).Inc()
+		logging.FromContext(r.Context()).Error().Err(err).Str("limit", limit).Msg("Error checking rate limit")
+		return true
+	}
+	if ok {
+		return true
+	}
+
+	rateLimited.With(prometheus.Labels{"limit": limit}).Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	writeError(w, r, ErrRateLimited, fmt.Sprintf("%s limit exceeded", limit), nil)
+	return false
+}
+
+// refund puts back n tokens taken from the limiter.
+func (l *RateLimiter) refund(r *http.Request, limiter ratelimit.Limiter, limit, key string, n int) {
+	if limiter == nil {
+		return
+	}
+	if err := limiter.Refund(r.Context(), key, n); err != nil {
+		rateLimitErrors.With(prometheus.Labels{"limit": limit}).Inc()
+		logging.FromContext(r.Context()).Error().Err(err).Str("limit", limit).Msg("Error refunding rate limit")
+	}
+}
+
+// clientKey returns the key the client of r is limited by. Authenticated
+// clients are limited by name, other clients by address: unverified tokens
+// can't be trusted, a client could send a new one with every request.
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if l.key == config.RateLimitKeyAPIKey {
+		if id, ok := auth.FromContext(r.Context()); ok && len(id.Client) > 0 {
+			return "client:" + id.Client
+		}
+	}
+	// RemoteAddr holds the client IP once chi's RealIP middleware ran
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// retryAfterSeconds rounds d up to whole seconds, as used by Retry-After.
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
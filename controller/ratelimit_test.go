@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/auth"
+	"github.com/brave/go-translate/config"
+)
+
+func newTestRateLimiter(t *testing.T, conf config.RateLimitConfig) http.Handler {
+	if conf.Window == 0 {
+		conf.Window = time.Minute
+	}
+	if conf.Key == "" {
+		conf.Key = config.RateLimitKeyIP
+	}
+	conf.Backend = config.RateLimitMemory
+
+	limiter, err := NewRateLimiter(&conf)
+	assert.NoError(t, err)
+	return limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestRateLimiterRequests(t *testing.T) {
+	handler := newTestRateLimiter(t, config.RateLimitConfig{Requests: 2})
+
+	before := testutil.ToFloat64(rateLimited.WithLabelValues(limitRequests))
+	codes := []int{}
+	for _, addr := range []string{"10.0.0.1:1234", "10.0.0.1:4321", "10.0.0.1:1234", "10.0.0.2:1234"} {
+		r := newTranslateRequest("sl=en&tl=es", "Hello")
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+
+		if w.Code == http.StatusTooManyRequests {
+			assert.Equal(t, "30", w.Header().Get("Retry-After"))
+			resp := decodeError(t, w)
+			assert.Equal(t, ErrRateLimited, resp.Code)
+			assert.True(t, resp.Retryable)
+		}
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK}, codes)
+	assert.Equal(t, before+1, testutil.ToFloat64(rateLimited.WithLabelValues(limitRequests)))
+}
+
+func TestRateLimiterCharacters(t *testing.T) {
+	handler := newTestRateLimiter(t, config.RateLimitConfig{Characters: 10})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newTranslateRequest("sl=en&tl=es", "Hello", "Wörld"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newTranslateRequest("sl=en&tl=es", "!"))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "6", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimiterBothLimits(t *testing.T) {
+	handler := newTestRateLimiter(t, config.RateLimitConfig{Requests: 2, Characters: 10})
+
+	send := func(q string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newTranslateRequest("sl=en&tl=es", q))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("Hello"))
+	// rejected on characters, which leaves the request allowance alone
+	assert.Equal(t, http.StatusTooManyRequests, send("Hello Wörld"))
+	assert.Equal(t, http.StatusOK, send("Hola"))
+	assert.Equal(t, http.StatusTooManyRequests, send("!"))
+}
+
+func TestRateLimiterAPIKey(t *testing.T) {
+	handler := newTestRateLimiter(t, config.RateLimitConfig{Requests: 1, Key: config.RateLimitKeyAPIKey})
+
+	send := func(client, token string) int {
+		r := newTranslateRequest("sl=en&tl=es", "Hello")
+		if len(client) > 0 {
+			r = r.WithContext(auth.NewContext(r.Context(), auth.Identity{Client: client, Method: auth.MethodAPIKey}))
+		}
+		if len(token) > 0 {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("client-a", ""))
+	assert.Equal(t, http.StatusTooManyRequests, send("client-a", ""))
+	// clients are told apart by name, not by address
+	assert.Equal(t, http.StatusOK, send("client-b", ""))
+	// clients which are not authenticated are limited by address, whatever
+	// token they send
+	assert.Equal(t, http.StatusOK, send("", "token-a"))
+	assert.Equal(t, http.StatusTooManyRequests, send("", "token-b"))
+	assert.Equal(t, http.StatusTooManyRequests, send("", ""))
+}
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	limiter, err := NewRateLimiter(&config.RateLimitConfig{Window: time.Minute})
+	assert.NoError(t, err)
+	assert.Nil(t, limiter)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	chiware "github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+)
+
+// requestIDField is the log field holding the request ID.
+const requestIDField = "req_id"
+
+// RequestID makes the ID assigned by chi's RequestID middleware visible outside
+// of the service: it is echoed in the X-Request-Id response header and added to
+// every log line of the request, so that failures seen by the browser can be
+// correlated with the backend logs. It must be installed after chi's RequestID
+// middleware and hlog.NewHandler, and before the request logger.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r.Context())
+		if len(id) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set(chiware.RequestIDHeader, id)
+		logger := zerolog.Ctx(r.Context()).With().Str(requestIDField, id).Logger()
+		next.ServeHTTP(w, r.WithContext(logger.WithContext(r.Context())))
+	})
+}
+
+// requestID returns the ID of the request handled with ctx, or an empty string.
+func requestID(ctx context.Context) string {
+	return chiware.GetReqID(ctx)
+}
+
+// setUpstreamRequestID forwards the request ID of ctx to Lingvanex.
+func setUpstreamRequestID(ctx context.Context, header http.Header) {
+	if id := requestID(ctx); len(id) > 0 {
+		header.Set(chiware.RequestIDHeader, id)
+	}
+}
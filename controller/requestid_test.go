@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chiware "github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func withRequestID(r *http.Request, id string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), chiware.RequestIDKey, id))
+}
+
+func TestRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs)
+
+	handler := RequestID(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		zerolog.Ctx(r.Context()).Info().Msg("handled")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = withRequestID(r.WithContext(logger.WithContext(r.Context())), "host/abc-000001")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, "host/abc-000001", w.Header().Get("X-Request-Id"))
+	assert.Contains(t, logs.String(), `"req_id":"host/abc-000001"`)
+}
+
+func TestTranslateForwardsRequestID(t *testing.T) {
+	var upstreamID string
+	setupTestEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		upstreamID = r.Header.Get("X-Request-Id")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("bad gateway"))
+	})
+
+	w := httptest.NewRecorder()
+	Translate(w, withRequestID(newTranslateRequest("sl=en&tl=es", "Hello"), "host/abc-000002"))
+
+	assert.Equal(t, "host/abc-000002", upstreamID)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "host/abc-000002")
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/brave/go-translate/assets"
+)
+
+var (
+	// ScriptOrigins are the origins of this service the translate element
+	// scripts are rewritten for, as scheme://host[:port].
+	ScriptOrigins []string
+
+	scriptRewriteMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_script_rewrite_misses_total",
+		Help: "The total number of values of the translate element scripts which could not be rewritten, by file",
+	},
+		[]string{"file"},
+	)
+)
+
+// scriptOrigin returns the origin r was sent to if the scripts are rewritten
+// for it, or an empty string.
+func scriptOrigin(r *http.Request) string {
+	if len(ScriptOrigins) == 0 {
+		return ""
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	origin := scheme + "://" + strings.ToLower(r.Host)
+	if !slices.Contains(ScriptOrigins, origin) {
+		return ""
+	}
+	return origin
+}
+
+// rewriteScript returns the body of the static file at name rewritten for
+// origin. Values which are not found, e.g. because Google changed the script,
+// are left alone and counted.
+func rewriteScript(name string, body []byte, origin string) []byte {
+	rewrites, ok := assets.ScriptRewrites[name]
+	if !ok || len(origin) == 0 {
+		return body
+	}
+	scheme, host, _ := strings.Cut(origin, "://")
+	values := strings.NewReplacer("{origin}", origin, "{scheme}", scheme, "{host}", host)
+
+	script := string(body)
+	for _, rewrite := range rewrites {
+		if !strings.Contains(script, rewrite.Old) {
+			scriptRewriteMisses.With(prometheus.Labels{"file": name}).Inc()
+			continue
+		}
+		script = strings.ReplaceAll(script, rewrite.Old, values.Replace(rewrite.New))
+	}
+	return []byte(script)
+}
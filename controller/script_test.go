@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"io/fs"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/assets"
+	"github.com/brave/go-translate/config"
+)
+
+func TestRewriteScript(t *testing.T) {
+	body, err := fs.ReadFile(assets.FS, "static/v1/element.js")
+	assert.NoError(t, err)
+
+	before := testutil.ToFloat64(scriptRewriteMisses.WithLabelValues("static/v1/element.js"))
+	script := string(rewriteScript("static/v1/element.js", body, "http://127.0.0.1:8195"))
+	// every value is found in the script as downloaded from Google
+	assert.Equal(t, before, testutil.ToFloat64(scriptRewriteMisses.WithLabelValues("static/v1/element.js")))
+
+	assert.Contains(t, script, `const h='127.0.0.1:8195'`)
+	assert.Contains(t, script, `c._pas='http://';`)
+	assert.Contains(t, script, `c._ps='http://127.0.0.1:8195/static/v1/css/translateelement.css'`)
+	assert.Contains(t, script, `c._cjlc('http://127.0.0.1:8195/static/v1/js/element/main.js')`)
+	assert.NotContains(t, script, `translate_static\/js\/element\/main.js`)
+
+	// scripts are left alone without an origin, other files always
+	assert.Equal(t, body, rewriteScript("static/v1/element.js", body, ""))
+	assert.Equal(t, body, rewriteScript("static/v1/js/element/main.js", body, "http://127.0.0.1:8195"))
+
+	before = testutil.ToFloat64(scriptRewriteMisses.WithLabelValues("static/v1/element.js"))
+	rewriteScript("static/v1/element.js", []byte("changed by Google"), "http://127.0.0.1:8195")
+	assert.Equal(t, before+4, testutil.ToFloat64(scriptRewriteMisses.WithLabelValues("static/v1/element.js")))
+}
+
+func TestServeStaticFileRewritten(t *testing.T) {
+	conf := config.Default()
+	conf.Static.Origins = []string{"https://translate.example.com", "http://localhost:8195"}
+	r := newTestRouter(t, conf)
+	original, err := fs.ReadFile(assets.FS, "static/v1/element.js")
+	assert.NoError(t, err)
+
+	get := func(host, proto string) (string, string) {
+		req := http.Header{}
+		if len(proto) > 0 {
+			req.Set("X-Forwarded-Proto", proto)
+		}
+		w := getStatic(hostHandler(r, host), "/static/v1/element.js", req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		// shared caches must keep a variant per origin
+		assert.Equal(t, []string{"Accept-Encoding", "Host, X-Forwarded-Proto"}, w.Header().Values("Vary"))
+		return w.Body.String(), w.Header().Get("ETag")
+	}
+
+	prod, prodETag := get("translate.example.com", "https")
+	assert.Contains(t, prod, `c._cjlc('https://translate.example.com/static/v1/js/element/main.js')`)
+	local, localETag := get("localhost:8195", "")
+	assert.Contains(t, local, `const h='localhost:8195'`)
+	assert.NotEqual(t, prodETag, localETag)
+
+	// other origins get the script unchanged
+	other, otherETag := get("evil.example.com", "https")
+	assert.Equal(t, string(original), other)
+	assert.NotEqual(t, prodETag, otherETag)
+	// the scheme is part of the origin
+	plain, _ := get("translate.example.com", "")
+	assert.Equal(t, string(original), plain)
+
+	// files without origin specific values don't vary by origin
+	w := getStatic(hostHandler(r, "translate.example.com"), "/static/v1/js/element/main.js", nil)
+	assert.Equal(t, []string{"Accept-Encoding"}, w.Header().Values("Vary"))
+
+	// variants are kept, and shared by files without origin specific values
+	staticFiles.mu.Lock()
+	assert.Len(t, staticFiles.assets, 5)
+	staticFiles.mu.Unlock()
+}
+
+func TestStaticAssetHashByOrigin(t *testing.T) {
+	// even if no value was rewritten, e.g. after Google changed the script
+	body := []byte("// changed by Google")
+	plain, err := newStaticAsset("static/v1/element.js", body, "")
+	assert.NoError(t, err)
+	a, err := newStaticAsset("static/v1/element.js", body, "https://a.example.com")
+	assert.NoError(t, err)
+	b, err := newStaticAsset("static/v1/element.js", body, "https://b.example.com")
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, plain.hash, a.hash)
+	assert.NotEqual(t, a.hash, b.hash)
+}
+
+// hostHandler sends requests to h with the Host header set to host.
+func hostHandler(h http.Handler, host string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Host = host
+		h.ServeHTTP(w, r)
+	})
+}
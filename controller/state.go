@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// unhealthyThreshold is the number of consecutive failed upstream requests
+// after which an endpoint is reported as unhealthy.
+const unhealthyThreshold = 3
+
+// Health states of an endpoint.
+const (
+	healthUnknown   = "unknown"
+	healthHealthy   = "healthy"
+	healthDegraded  = "degraded"
+	healthUnhealthy = "unhealthy"
+)
+
+// endpointState holds the runtime state of an endpoint. It is kept separately
+// from LnxEndpointConfiguration so that it survives configuration reloads.
+type endpointState struct {
+	// whether new requests are kept away from the endpoint
+	drained atomic.Bool
+	// the number of requests currently sent to the endpoint
+	inFlight atomic.Int64
+	// bounds the number of translate requests in flight
+	slots slots
+
+	mu                  sync.Mutex
+	lastSuccess         time.Time
+	lastFailure         time.Time
+	lastError           string
+	consecutiveFailures int
+}
+
+// EndpointHealth describes the health of an endpoint as observed from the
+// requests sent to it.
+type EndpointHealth struct {
+	Status              string     `json:"status"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+var endpointStates sync.Map // endpoint URL -> *endpointState
+
+// stateOf returns the runtime state of the endpoint, creating it on first use.
+func stateOf(endpoint string) *endpointState {
+	if state, ok := endpointStates.Load(endpoint); ok {
+		return state.(*endpointState)
+	}
+	state, _ := endpointStates.LoadOrStore(endpoint, &endpointState{})
+	return state.(*endpointState)
+}
+
+// isDrained reports whether new requests are kept away from the endpoint.
+func isDrained(endpoint string) bool {
+	state, ok := endpointStates.Load(endpoint)
+	return ok && state.(*endpointState).drained.Load()
+}
+
+func (s *endpointState) recordSuccess() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSuccess = time.Now()
+	s.consecutiveFailures = 0
+}
+
+func (s *endpointState) recordFailure(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastFailure = time.Now()
+	s.lastError = message
+	s.consecutiveFailures++
+}
+
+func (s *endpointState) health() EndpointHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := EndpointHealth{
+		ConsecutiveFailures: s.consecutiveFailures,
+		LastError:           s.lastError,
+	}
+	if !s.lastSuccess.IsZero() {
+		lastSuccess := s.lastSuccess
+		h.LastSuccess = &lastSuccess
+	}
+	if !s.lastFailure.IsZero() {
+		lastFailure := s.lastFailure
+		h.LastFailure = &lastFailure
+	}
+
+	switch {
+	case s.lastSuccess.IsZero() && s.lastFailure.IsZero():
+		h.Status = healthUnknown
+	case s.consecutiveFailures >= unhealthyThreshold:
+		h.Status = healthUnhealthy
+	case s.consecutiveFailures > 0:
+		h.Status = healthDegraded
+	default:
+		h.Status = healthHealthy
+	}
+	return h
+}
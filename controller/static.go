@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brave/go-translate/assets"
+)
+
+// Content encodings of the precompressed static files, by preference.
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// staticHashLength is the number of hex digits of the content hash used in
+// ETags and hashed URLs.
+const staticHashLength = 16
+
+// immutableCacheControl is the Cache-Control value of hashed URLs, whose
+// content never changes.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+var (
+	// StaticMaxAge is how long browsers may cache the static files.
+	StaticMaxAge = 24 * time.Hour
+
+	// staticPaths are the static files served by TranslateRouter.
+	staticPaths = []string{
+		"/static/v1/element.js",
+		"/static/v1/js/element/main.js",
+		"/static/v1/css/translateelement.css",
+	}
+
+	// staticFiles holds the static files of the translation script, at the
+	// paths they are served under. It defaults to the files embedded in the
+	// binary.
+	staticFiles = newStaticAssets(assets.FS)
+)
+
+// staticAsset is a static file with its precompressed variants.
+type staticAsset struct {
+	contentType string
+	hash        string
+	// bodies by content encoding, the empty encoding being the identity
+	bodies map[string][]byte
+
+	modTime time.Time
+	size    int64
+}
+
+// staticKey identifies a static file as rendered for an origin.
+type staticKey struct {
+	name   string
+	origin string
+}
+
+// staticAssets caches the static files of a file system, rendered for each
+// origin. Files are read and compressed once, and again whenever they change,
+// which only happens when serving from a directory during development.
+type staticAssets struct {
+	fsys fs.FS
+
+	mu     sync.Mutex
+	assets map[staticKey]*staticAsset
+}
+
+func newStaticAssets(fsys fs.FS) *staticAssets {
+	return &staticAssets{fsys: fsys, assets: make(map[staticKey]*staticAsset)}
+}
+
+// get returns the static file at name, which is relative to the root of the
+// file system, rewritten for origin if not empty.
+func (s *staticAssets) get(name, origin string) (*staticAsset, error) {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+
+	if _, ok := assets.ScriptRewrites[name]; !ok {
+		// the file is the same for every origin
+		origin = ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := staticKey{name: name, origin: origin}
+	if asset, ok := s.assets[key]; ok && asset.modTime.Equal(info.ModTime()) && asset.size == info.Size() {
+		return asset, nil
+	}
+	body, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	asset, err := newStaticAsset(name, rewriteScript(name, body, origin), origin)
+	if err != nil {
+		return nil, err
+	}
+	asset.modTime, asset.size = info.ModTime(), info.Size()
+	s.assets[key] = asset
+	return asset, nil
+}
+
+// preload reads and compresses the files for each origin ahead of the first
+// request. Missing files are skipped, they are answered with 404.
+func (s *staticAssets) preload(paths, origins []string) error {
+	for _, p := range paths {
+		for _, origin := range append([]string{""}, origins...) {
+			if _, err := s.get(strings.TrimPrefix(p, "/"), origin); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("error loading static file %s: %v", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// newStaticAsset returns the static file at name with the given body. The
+// origin it was rewritten for, if any, is part of its hash so that the ETags
+// of the variants never collide.
+func newStaticAsset(name string, body []byte, origin string) (*staticAsset, error) {
+	h := sha256.New()
+	if len(origin) > 0 {
+		h.Write([]byte(origin + "\n"))
+	}
+	h.Write(body)
+	sum := h.Sum(nil)
+	asset := &staticAsset{
+		contentType: mime.TypeByExtension(path.Ext(name)),
+		hash:        hex.EncodeToString(sum[:])[:staticHashLength],
+		bodies:      map[string][]byte{"": body},
+	}
+
+	var buf bytes.Buffer
+	br := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	if _, err := br.Write(body); err != nil {
+		return nil, err
+	}
+	if err := br.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() < len(body) {
+		asset.bodies[encodingBrotli] = bytes.Clone(buf.Bytes())
+	}
+
+	buf.Reset()
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() < len(body) {
+		asset.bodies[encodingGzip] = bytes.Clone(buf.Bytes())
+	}
+	return asset, nil
+}
+
+// encoding returns the preferred content encoding of the asset accepted by
+// the client, or the empty string for the identity.
+func (a *staticAsset) encoding(acceptEncoding string) string {
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		if _, ok := a.bodies[encoding]; ok && acceptsEncoding(acceptEncoding, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header value allows the
+// content encoding.
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	for item := range strings.SplitSeq(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(item, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, encoding) && name != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// hashedPath returns the route pattern of the hashed URL of a static file,
+// e.g. /static/v1/element.{hash}.js.
+func hashedPath(p string) string {
+	ext := path.Ext(p)
+	return fmt.Sprintf("%s.{hash:[0-9a-f]{%d}}%s", strings.TrimSuffix(p, ext), staticHashLength, ext)
+}
+
+// ServeStaticFile serves static files of the translation script, rewritten
+// for the origin of the request if it is one of ScriptOrigins. Files are sent
+// precompressed if the client accepts it, and conditional requests are
+// answered from their content hash.
+func ServeStaticFile(w http.ResponseWriter, r *http.Request) {
+	serveStaticFile(w, r, "")
+}
+
+// ServeHashedStaticFile serves static files at URLs holding their content
+// hash, which are cached forever. Outdated hashes are answered with 404.
+func ServeHashedStaticFile(w http.ResponseWriter, r *http.Request) {
+	serveStaticFile(w, r, chi.URLParam(r, "hash"))
+}
+
+func serveStaticFile(w http.ResponseWriter, r *http.Request, hash string) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if len(hash) > 0 {
+		name = strings.TrimSuffix(name, path.Ext(name))
+		name = strings.TrimSuffix(name, "."+hash) + path.Ext(r.URL.Path)
+	}
+
+	asset, err := staticFiles.get(name, scriptOrigin(r))
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(hash) > 0 && hash != asset.hash) {
+		writeError(w, r, ErrNotFound, "static file not found", nil)
+		return
+	}
+	if err != nil {
+		handleInternalServerError(w, r, "error reading static file", err)
+		return
+	}
+
+	encoding := asset.encoding(r.Header.Get("Accept-Encoding"))
+	etag := asset.hash
+	if len(encoding) > 0 {
+		// each representation needs its own strong ETag
+		etag += "-" + encoding
+		w.Header().Set("Content-Encoding", encoding)
+	}
+
+	w.Header().Set("Content-Security-Policy", "require-trusted-types-for 'script'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
+	w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	w.Header().Set("Content-Type", asset.contentType)
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.Header().Add("Vary", "Accept-Encoding")
+	if _, ok := assets.ScriptRewrites[name]; ok && len(ScriptOrigins) > 0 {
+		// the script is rewritten for the origin of the request
+		w.Header().Add("Vary", "Host, X-Forwarded-Proto")
+	}
+	if len(hash) > 0 {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(StaticMaxAge.Seconds())))
+	}
+
+	// handles If-None-Match, HEAD and range requests
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(asset.bodies[encoding]))
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/assets"
+	"github.com/brave/go-translate/config"
+)
+
+func newTestRouter(t *testing.T, conf config.Config) chi.Router {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"code_alpha_1": "en", "codeName": "English"}, {"code_alpha_1": "es", "codeName": "Spanish"}]`))
+	}))
+	t.Cleanup(ts.Close)
+	files, maxAge := staticFiles, StaticMaxAge
+	t.Cleanup(func() { staticFiles, StaticMaxAge = files, maxAge })
+
+	conf.Lnx.Hosts = []string{ts.URL}
+	r, err := TranslateRouter(context.Background(), &conf)
+	assert.NoError(t, err)
+	return r
+}
+
+func getStatic(r http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestServeStaticFileEmbedded(t *testing.T) {
+	r := newTestRouter(t, config.Default())
+	// the embedded files don't depend on the working directory
+	t.Chdir(t.TempDir())
+
+	for _, path := range staticPaths {
+		want, err := fs.ReadFile(assets.FS, path[1:])
+		assert.NoError(t, err)
+
+		w := getStatic(r, path, nil)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, string(want), w.Body.String(), path)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), path)
+		assert.Equal(t, "max-age=86400", w.Header().Get("Cache-Control"), path)
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"), path)
+		assert.Equal(t, "require-trusted-types-for 'script'", w.Header().Get("Content-Security-Policy"), path)
+	}
+	assert.Equal(t, "text/javascript; charset=utf-8", getStatic(r, staticPaths[0], nil).Header().Get("Content-Type"))
+	assert.Equal(t, "text/css; charset=utf-8", getStatic(r, staticPaths[2], nil).Header().Get("Content-Type"))
+
+	assert.Equal(t, http.StatusNotFound, getStatic(r, "/static/v1/js/element/other.js", nil).Code)
+}
+
+func TestServeStaticFileOverride(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "static", "v1", "element.js")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+	assert.NoError(t, os.WriteFile(file, []byte("// development"), 0o644))
+	conf := config.Default()
+	conf.AssetsDir = dir
+	r := newTestRouter(t, conf)
+
+	w := getStatic(r, "/static/v1/element.js", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "// development", w.Body.String())
+
+	// changes are served without a restart
+	assert.NoError(t, os.WriteFile(file, []byte("// changed"), 0o644))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(file, later, later))
+	assert.Equal(t, "// changed", getStatic(r, "/static/v1/element.js", nil).Body.String())
+
+	// files missing from the override directory are not taken from the binary
+	assert.Equal(t, http.StatusNotFound, getStatic(r, "/static/v1/js/element/main.js", nil).Code)
+}
+
+func TestServeStaticFileCompressed(t *testing.T) {
+	r := newTestRouter(t, config.Default())
+	want, err := fs.ReadFile(assets.FS, "static/v1/js/element/main.js")
+	assert.NoError(t, err)
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	}
+	for accept, encoding := range map[string]string{
+		"gzip, deflate, br": "br",
+		"gzip":              "gzip",
+		"br;q=0, gzip":      "gzip",
+		"*":                 "br",
+		"identity":          "",
+	} {
+		w := getStatic(r, "/static/v1/js/element/main.js", http.Header{"Accept-Encoding": {accept}})
+		assert.Equal(t, http.StatusOK, w.Code, accept)
+		assert.Equal(t, encoding, w.Header().Get("Content-Encoding"), accept)
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), accept)
+
+		body := io.Reader(w.Body)
+		if len(encoding) > 0 {
+			assert.Less(t, w.Body.Len(), len(want), accept)
+			body, err = decoders[encoding](body)
+			assert.NoError(t, err)
+		}
+		got, err := io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(want, got), accept)
+	}
+}
+
+func TestServeStaticFileETag(t *testing.T) {
+	r := newTestRouter(t, config.Default())
+
+	w := getStatic(r, "/static/v1/element.js", nil)
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{16}"$`, etag)
+	gzipETag := getStatic(r, "/static/v1/element.js", http.Header{"Accept-Encoding": {"gzip"}}).Header().Get("ETag")
+	assert.Equal(t, strings.TrimSuffix(etag, `"`)+`-gzip"`, gzipETag)
+
+	w = getStatic(r, "/static/v1/element.js", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	w = getStatic(r, "/static/v1/element.js", http.Header{"If-None-Match": {gzipETag}, "Accept-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = getStatic(r, "/static/v1/element.js", http.Header{"If-None-Match": {`"0000000000000000"`}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	// the hash of one file doesn't match another
+	w = getStatic(r, "/static/v1/js/element/main.js", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServeHashedStaticFile(t *testing.T) {
+	conf := config.Default()
+	conf.Static.HashedURLs = true
+	r := newTestRouter(t, conf)
+
+	etag := getStatic(r, "/static/v1/js/element/main.js", nil).Header().Get("ETag")
+	hash := strings.Trim(etag, `"`)
+
+	w := getStatic(r, "/static/v1/js/element/main."+hash+".js", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, immutableCacheControl, w.Header().Get("Cache-Control"))
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, "text/javascript; charset=utf-8", w.Header().Get("Content-Type"))
+
+	// outdated hashes are not served
+	assert.Equal(t, http.StatusNotFound, getStatic(r, "/static/v1/js/element/main.0000000000000000.js", nil).Code)
+	assert.Equal(t, http.StatusNotFound, getStatic(r, "/static/v1/element."+hash+".js", nil).Code)
+
+	// hashed URLs are only served if enabled
+	r = newTestRouter(t, config.Default())
+	assert.Equal(t, http.StatusNotFound, getStatic(r, "/static/v1/js/element/main."+hash+".js", nil).Code)
+}
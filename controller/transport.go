@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/brave/go-translate/config"
+)
+
+var (
+	upstreamConnsOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "translate_upstream_connections_open",
+		Help: "The number of open connections to a Lingvanex endpoint",
+	},
+		[]string{"endpoint"},
+	)
+	upstreamConnsDialed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_connections_dialed_total",
+		Help: "The total number of connections dialed to a Lingvanex endpoint",
+	},
+		[]string{"endpoint", "result"},
+	)
+	upstreamConnsAcquired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_upstream_connections_acquired_total",
+		Help: "The total number of connections acquired for Lingvanex requests, by whether they were reused from the pool",
+	},
+		[]string{"endpoint", "reused"},
+	)
+)
+
+// upstreamClients holds one HTTP client per Lingvanex endpoint, so that
+// connections are pooled and reused across requests.
+type upstreamClients struct {
+	mu      sync.Mutex
+	conf    config.TransportConfig
+	clients map[string]*http.Client
+}
+
+var clients = &upstreamClients{
+	conf:    config.DefaultTransportConfig(),
+	clients: make(map[string]*http.Client),
+}
+
+// SetTransportConfig replaces the transport configuration. Idle connections of
+// the existing clients are closed and new clients are created on demand.
+func SetTransportConfig(conf config.TransportConfig) {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+
+	for _, client := range clients.clients {
+		client.CloseIdleConnections()
+	}
+	clients.conf = conf
+	clients.clients = make(map[string]*http.Client)
+}
+
+// getHTTPClient returns the shared client for the endpoint, creating it on
+// first use.
+func getHTTPClient(endpoint string) *http.Client {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+
+	if client, ok := clients.clients[endpoint]; ok {
+		return client
+	}
+	client := &http.Client{
+		Transport: &tracingTransport{endpoint: endpoint, base: newTransport(endpoint, clients.conf)},
+		Timeout:   clients.conf.RequestTimeout,
+	}
+	clients.clients[endpoint] = client
+	return client
+}
+
+// newTransport returns a transport for the endpoint configured from conf.
+func newTransport(endpoint string, conf config.TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   conf.DialTimeout,
+		KeepAlive: conf.KeepAlive,
+	}
+
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	switch conf.HTTP2 {
+	case config.HTTP2On:
+		protocols.SetHTTP2(true)
+	case config.HTTP2Cleartext:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	}
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				upstreamConnsDialed.With(prometheus.Labels{"endpoint": endpoint, "result": "error"}).Inc()
+				return nil, err
+			}
+			upstreamConnsDialed.With(prometheus.Labels{"endpoint": endpoint, "result": "success"}).Inc()
+			upstreamConnsOpen.With(prometheus.Labels{"endpoint": endpoint}).Inc()
+			return &trackedConn{Conn: conn, endpoint: endpoint}, nil
+		},
+		MaxIdleConns:          conf.MaxIdleConns,
+		MaxIdleConnsPerHost:   conf.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       conf.MaxConnsPerHost,
+		IdleConnTimeout:       conf.IdleConnTimeout,
+		TLSHandshakeTimeout:   conf.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: conf.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		Protocols:             &protocols,
+	}
+}
+
+// trackedConn decrements the open connections gauge when the connection is
+// closed.
+type trackedConn struct {
+	net.Conn
+	endpoint string
+	once     sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		upstreamConnsOpen.With(prometheus.Labels{"endpoint": c.endpoint}).Dec()
+	})
+	return c.Conn.Close()
+}
+
+// tracingTransport records whether upstream requests reused a pooled
+// connection.
+type tracingTransport struct {
+	endpoint string
+	base     http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			upstreamConnsAcquired.With(prometheus.Labels{
+				"endpoint": t.endpoint,
+				"reused":   strconv.FormatBool(info.Reused),
+			}).Inc()
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
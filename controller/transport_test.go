@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/config"
+)
+
+func TestGetHTTPClientReusesConnections(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	SetTransportConfig(config.DefaultTransportConfig())
+	client := getHTTPClient(ts.URL)
+	assert.Same(t, client, getHTTPClient(ts.URL))
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		assert.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(upstreamConnsDialed.WithLabelValues(ts.URL, "success")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(upstreamConnsAcquired.WithLabelValues(ts.URL, "true")))
+}
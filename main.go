@@ -2,9 +2,21 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/brave/go-translate/assets/update"
 	"github.com/brave/go-translate/server"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "update-assets" {
+		if err := update.Main(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	server.StartServer()
 }
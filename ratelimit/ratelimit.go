@@ -0,0 +1,112 @@
+// Package ratelimit provides token bucket rate limiters keyed by client, kept
+// either in memory or in Redis to be shared between instances.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter takes tokens from per-key token buckets.
+type Limiter interface {
+	// Allow takes n tokens from the bucket of key. If they are not available,
+	// nothing is taken and the time until they will be is returned.
+	Allow(ctx context.Context, key string, n int) (bool, time.Duration, error)
+	// Refund puts back n tokens taken from the bucket of key, e.g. when the
+	// request was rejected by another limit. Buckets never exceed their limit.
+	Refund(ctx context.Context, key string, n int) error
+}
+
+// Bucket describes the token buckets of a limiter: a bucket holds up to Limit
+// tokens and is refilled at Limit tokens per Window.
+type Bucket struct {
+	Limit  int
+	Window time.Duration
+}
+
+// perNano returns the number of tokens refilled per nanosecond.
+func (b Bucket) perNano() float64 {
+	return float64(b.Limit) / float64(b.Window)
+}
+
+// clamp limits n to the bucket size, so that requests larger than a whole
+// window are allowed once the bucket is full instead of never.
+func (b Bucket) clamp(n int) int {
+	return min(n, b.Limit)
+}
+
+// Memory is a Limiter keeping the buckets in memory.
+type Memory struct {
+	bucket Bucket
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemory returns an in-memory limiter.
+func NewMemory(bucket Bucket) *Memory {
+	return &Memory{
+		bucket:  bucket,
+		now:     time.Now,
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+// Allow implements Limiter.
+func (m *Memory) Allow(_ context.Context, key string, n int) (bool, time.Duration, error) {
+	n = m.bucket.clamp(n)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(m.bucket.Limit), last: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(m.bucket.Limit), b.tokens+float64(now.Sub(b.last))*m.bucket.perNano())
+	b.last = now
+
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
+		return true, 0, nil
+	}
+	return false, time.Duration(math.Ceil((float64(n) - b.tokens) / m.bucket.perNano())), nil
+}
+
+// Refund implements Limiter.
+func (m *Memory) Refund(_ context.Context, key string, n int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b, ok := m.buckets[key]; ok {
+		b.tokens = math.Min(float64(m.bucket.Limit), b.tokens+float64(m.bucket.clamp(n)))
+	}
+	return nil
+}
+
+// sweep drops the buckets which are full again, at most once per window, to
+// bound the memory used by clients which went away.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < m.bucket.Window {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= m.bucket.Window {
+			delete(m.buckets, key)
+		}
+	}
+}
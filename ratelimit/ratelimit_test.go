@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLimiter(t *testing.T, limiter Limiter, advance func(time.Duration)) {
+	ctx := context.Background()
+
+	// the whole window may be used at once
+	for range 3 {
+		ok, _, err := limiter.Allow(ctx, "a", 1)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, retryAfter, err := limiter.Allow(ctx, "a", 1)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.InDelta(t, 20*time.Second, retryAfter, float64(time.Millisecond))
+
+	// other keys have their own bucket
+	ok, _, err = limiter.Allow(ctx, "b", 3)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// tokens are refilled steadily
+	advance(20 * time.Second)
+	ok, _, err = limiter.Allow(ctx, "a", 1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, _, err = limiter.Allow(ctx, "a", 1)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// requests larger than the bucket pass once it is full
+	advance(time.Minute)
+	ok, _, err = limiter.Allow(ctx, "a", 10)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// refunded tokens can be taken again, up to the limit
+	assert.NoError(t, limiter.Refund(ctx, "a", 2))
+	ok, _, err = limiter.Allow(ctx, "a", 2)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, limiter.Refund(ctx, "a", 10))
+	ok, _, err = limiter.Allow(ctx, "a", 3)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, _, err = limiter.Allow(ctx, "a", 1)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemory(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewMemory(Bucket{Limit: 3, Window: time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	testLimiter(t, limiter, func(d time.Duration) { now = now.Add(d) })
+
+	// full buckets are dropped
+	now = now.Add(2 * time.Minute)
+	_, _, _ = limiter.Allow(context.Background(), "c", 1)
+	assert.Len(t, limiter.buckets, 1)
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	now := time.Unix(1700000000, 0)
+	limiter := NewRedis(client, "test:", Bucket{Limit: 3, Window: time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	testLimiter(t, limiter, func(d time.Duration) { now = now.Add(d) })
+	assert.True(t, server.Exists("test:a"))
+
+	server.Close()
+	_, _, err := limiter.Allow(context.Background(), "a", 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and takes from a bucket atomically. Buckets are
+// hashes holding the tokens left and the time of the last update in
+// milliseconds, they expire once they would be full again.
+var tokenBucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local per_ms = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - ts) * per_ms)
+
+local allowed, wait = 0, 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+else
+	wait = math.ceil((n - tokens) / per_ms)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(limit / per_ms))
+return {allowed, wait}
+`)
+
+// refundScript puts tokens back into a bucket, if it still exists.
+var refundScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+
+local tokens = tonumber(redis.call("HGET", KEYS[1], "tokens"))
+if tokens then
+	redis.call("HSET", KEYS[1], "tokens", tostring(math.min(limit, tokens + n)))
+end
+return 0
+`)
+
+// Redis is a Limiter keeping the buckets in Redis, so that all instances
+// share them.
+type Redis struct {
+	client redis.Scripter
+	prefix string
+	bucket Bucket
+	now    func() time.Time
+}
+
+// NewRedis returns a limiter storing its buckets in client under keys
+// starting with prefix.
+func NewRedis(client redis.Scripter, prefix string, bucket Bucket) *Redis {
+	return &Redis{client: client, prefix: prefix, bucket: bucket, now: time.Now}
+}
+
+// Allow implements Limiter.
+func (r *Redis) Allow(ctx context.Context, key string, n int) (bool, time.Duration, error) {
+	perMs := r.bucket.perNano() * float64(time.Millisecond)
+	res, err := tokenBucketScript.Run(ctx, r.client, []string{r.prefix + key},
+		r.bucket.Limit, perMs, r.now().UnixMilli(), r.bucket.clamp(n),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("error running rate limit script: %v", err)
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit script result %v", res)
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// Refund implements Limiter.
+func (r *Redis) Refund(ctx context.Context, key string, n int) error {
+	err := refundScript.Run(ctx, r.client, []string{r.prefix + key}, r.bucket.Limit, r.bucket.clamp(n)).Err()
+	if err != nil {
+		return fmt.Errorf("error running rate limit refund script: %v", err)
+	}
+	return nil
+}
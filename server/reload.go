@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/brave-intl/bat-go/libs/logging"
+
+	"github.com/brave/go-translate/config"
+	"github.com/brave/go-translate/controller"
+)
+
+// fileChecksum returns the checksum of the file content, or nil if it can't be read.
+func fileChecksum(path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// watchConfig calls reload on SIGHUP and, if interval is positive, whenever
+// the content of the file at path changes. It returns when ctx is done.
+func watchConfig(ctx context.Context, path string, interval time.Duration, reload func(reason string)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if len(path) > 0 && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	last := fileChecksum(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload("signal")
+		case <-tick:
+			sum := fileChecksum(path)
+			if string(sum) != string(last) {
+				last = sum
+				reload("file_changed")
+			}
+		}
+	}
+}
+
+// reloadEndpoints loads and validates the configuration and swaps in the new
+// endpoint configuration. On any error the current configuration stays in use.
+func reloadEndpoints(ctx context.Context, reason string) {
+	logger := logging.FromContext(ctx)
+
+	conf, err := config.Load()
+	if err != nil {
+		logger.Error().Err(err).Str("reason", reason).Msg("Rejected invalid configuration, keeping current endpoints")
+		return
+	}
+	err = controller.ReloadLnxEndpoint(ctx, &conf.Lnx)
+	if err != nil {
+		logger.Error().Err(err).Str("reason", reason).Msg("Failed to reload endpoints, keeping current endpoints")
+		return
+	}
+	logger.Info().
+		Str("reason", reason).
+		Interface("lnx", conf.Redacted().Lnx).
+		Msg("Reloaded endpoint configuration")
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("lnx: {}\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reasons := make(chan string, 1)
+	go watchConfig(ctx, path, 10*time.Millisecond, func(reason string) {
+		reasons <- reason
+	})
+
+	// give the watcher time to record the initial checksum
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, os.WriteFile(path, []byte("lnx: {hosts: []}\n"), 0o600))
+	select {
+	case reason := <-reasons:
+		assert.Equal(t, "file_changed", reason)
+	case <-time.After(time.Second):
+		t.Fatal("file change was not detected")
+	}
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	select {
+	case reason := <-reasons:
+		assert.Equal(t, "signal", reason)
+	case <-time.After(time.Second):
+		t.Fatal("SIGHUP was not handled")
+	}
+}
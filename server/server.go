@@ -5,7 +5,8 @@ import (
 	"context"
 	"net"
 	"net/http"
-	"time"
+	"os/signal"
+	"syscall"
 
 	"github.com/brave-intl/bat-go/libs/logging"
 	"github.com/brave-intl/bat-go/libs/middleware"
@@ -15,67 +16,129 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 
+	"github.com/brave/go-translate/config"
 	"github.com/brave/go-translate/controller"
+	"github.com/brave/go-translate/tracing"
 )
 
-func setupRouter(ctx context.Context, logger *zerolog.Logger) (context.Context, *chi.Mux, error) {
+// heartbeat responds to requests for path with 200 while the service is ready,
+// and 503 once it is shutting down.
+func heartbeat(path string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.URL.Path != path {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			if !controller.Ready() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte("."))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("."))
+		})
+	}
+}
+
+func setupRouter(ctx context.Context, logger *zerolog.Logger, conf *config.Config) (context.Context, *chi.Mux, error) {
 	r := chi.NewRouter()
 
 	r.Use(chiware.RequestID)
 	r.Use(chiware.RealIP)
-	r.Use(chiware.Heartbeat("/"))
-	r.Use(chiware.Timeout(60 * time.Second))
+	r.Use(heartbeat("/"))
+	r.Use(controller.HealthChecks)
+	r.Use(tracing.Middleware)
+	r.Use(controller.CORS(&conf.CORS))
+	r.Use(chiware.Timeout(conf.RouterTimeout))
 	r.Use(middleware.BearerToken)
 
 	if logger != nil {
-		// Also handles panic recovery
+		// Also handles panic recovery. The request ID is added to the logger
+		// ahead of the request logger, so that access log lines carry it.
 		r.Use(
 			hlog.NewHandler(*logger),
+			controller.RequestID,
 			hlog.UserAgentHandler("user_agent"),
-			hlog.RequestIDHandler("req_id", "Request-Id"),
 			middleware.RequestLogger(logger))
+	} else {
+		r.Use(controller.RequestID)
 	}
 	r.Get("/metrics", middleware.Metrics())
-	tr, err := controller.TranslateRouter(ctx)
+	tr, err := controller.TranslateRouter(ctx, conf)
 	r.Mount("/", tr)
 
 	return ctx, r, err
 }
 
-// StartServer starts the translate proxy server on port 8195
+// StartServer starts the translate proxy server on the configured port, 8195 by default.
+// It shuts down gracefully on SIGTERM or SIGINT.
 func StartServer() {
 	serverCtx, logger := logging.SetupLogger(context.Background())
 
-	serverCtx, r, err := setupRouter(serverCtx, logger)
+	conf, err := config.Load()
 	if err != nil {
-		logger.Panic().Err(err).Msg("service setup failed!")
+		logger.Panic().Err(err).Msg("invalid configuration!")
 	}
-	port := ":8195"
-
-	go func() {
-		logger.Info().
-			Str("port", ":9090").
-			Msg("Starting metrics server")
+	logger.Info().
+		Interface("config", conf.Redacted()).
+		Msg("Loaded configuration")
 
-		metricsErr := http.ListenAndServe(":9090", middleware.Metrics())
-		if metricsErr != nil {
-			sentry.CaptureException(metricsErr)
-			logger.Panic().Err(metricsErr).Msg("metrics HTTP server start failed!")
+	shutdownTracing, err := tracing.Setup(serverCtx, &conf.Tracing)
+	if err != nil {
+		logger.Panic().Err(err).Msg("tracing setup failed!")
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error().Err(err).Msg("Error flushing traces")
 		}
 	}()
 
+	serverCtx, r, err := setupRouter(serverCtx, logger, conf)
+	if err != nil {
+		logger.Panic().Err(err).Msg("service setup failed!")
+	}
+
+	// requests keep serverCtx, so that they aren't cancelled by the signal
+	signalCtx, stop := signal.NotifyContext(serverCtx, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Only the endpoint configuration is reloaded, other settings require a restart
+	go watchConfig(signalCtx, config.FilePath(), conf.ReloadInterval, func(reason string) {
+		reloadEndpoints(serverCtx, reason)
+	})
+
+	baseContext := func(_ net.Listener) context.Context {
+		return serverCtx
+	}
+	tlsConf, err := newTLSConfig(serverCtx, &conf.TLS)
+	if err != nil {
+		logger.Panic().Err(err).Msg("TLS setup failed!")
+	}
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+
+	servers := []*http.Server{
+		{Addr: conf.ListenAddr, Handler: r, BaseContext: baseContext, TLSConfig: tlsConf, Protocols: protocols},
+		{Addr: conf.MetricsAddr, Handler: middleware.Metrics(), BaseContext: baseContext},
+	}
+	if len(conf.Admin.Addr) > 0 {
+		servers = append(servers, &http.Server{Addr: conf.Admin.Addr, Handler: controller.AdminRouter(&conf.Admin), BaseContext: baseContext})
+	}
+
 	logger.Info().
-		Str("port", port).
+		Str("port", conf.ListenAddr).
+		Str("metrics_port", conf.MetricsAddr).
+		Str("admin_port", conf.Admin.Addr).
+		Bool("tls", tlsConf != nil).
 		Msg("Starting API server")
 
-	srv := http.Server{Addr: port, Handler: r,
-		BaseContext: func(_ net.Listener) context.Context {
-			return serverCtx
-		},
-	}
-	err = srv.ListenAndServe()
+	err = runServers(signalCtx, conf.ShutdownDelay, conf.ShutdownGracePeriod, servers...)
 	if err != nil {
 		sentry.CaptureException(err)
-		logger.Panic().Err(err)
+		logger.Panic().Err(err).Msg("HTTP server failed!")
 	}
+	logger.Info().Msg("Server stopped")
 }
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brave-intl/bat-go/libs/logging"
+
+	"github.com/brave/go-translate/controller"
+)
+
+// runServers serves each server on its address, over TLS if it has a TLS
+// configuration, until ctx is done or one of them fails. The service is then
+// marked as not ready, and after delay the servers stop accepting connections
+// and wait up to grace for the requests in flight, which are cut off
+// afterwards.
+func runServers(ctx context.Context, delay, grace time.Duration, servers ...*http.Server) error {
+	logger := logging.FromContext(ctx)
+
+	listeners := make([]net.Listener, 0, len(servers))
+	for _, srv := range servers {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, ln := range listeners {
+				_ = ln.Close()
+			}
+			return fmt.Errorf("error listening on %s: %v", srv.Addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	failed := make(chan error, len(servers))
+	for i, srv := range servers {
+		go func() {
+			var err error
+			if srv.TLSConfig != nil {
+				err = srv.ServeTLS(listeners[i], "", "")
+			} else {
+				err = srv.Serve(listeners[i])
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("error serving on %s: %v", srv.Addr, err)
+			}
+		}()
+	}
+	controller.SetReady(true)
+
+	var err error
+	select {
+	case <-ctx.Done():
+		logger.Info().Dur("delay", delay).Dur("grace_period", grace).Msg("Shutting down")
+	case err = <-failed:
+		logger.Error().Err(err).Msg("Server failed, shutting down")
+		delay = 0
+	}
+
+	controller.SetReady(false)
+	time.Sleep(delay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), grace)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Go(func() {
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				logger.Warn().Err(err).Str("addr", srv.Addr).Msg("Grace period exceeded, closing remaining connections")
+				_ = srv.Close()
+			}
+		})
+	}
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/controller"
+)
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	return ln.Addr().String()
+}
+
+func TestRunServersGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	srv := &http.Server{Addr: freeAddr(t), Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusOK)
+	})}
+	metricsSrv := &http.Server{Addr: freeAddr(t), Handler: http.NotFoundHandler()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- runServers(ctx, 0, 5*time.Second, srv, metricsSrv) }()
+
+	assert.Eventually(t, controller.Ready, time.Second, time.Millisecond)
+
+	status := make(chan int)
+	go func() {
+		resp, err := http.Get("http://" + srv.Addr)
+		if !assert.NoError(t, err) {
+			status <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	cancel()
+	assert.Eventually(t, func() bool { return !controller.Ready() }, time.Second, time.Millisecond)
+
+	// new connections are refused while the request in flight finishes
+	assert.Eventually(t, func() bool {
+		_, err := net.Dial("tcp", metricsSrv.Addr)
+		return err != nil
+	}, time.Second, time.Millisecond)
+	close(finish)
+
+	assert.Equal(t, http.StatusOK, <-status)
+	assert.NoError(t, <-done)
+}
+
+func TestRunServersGracePeriodExceeded(t *testing.T) {
+	started := make(chan struct{})
+	srv := &http.Server{Addr: freeAddr(t), Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- runServers(ctx, 0, 50*time.Millisecond, srv) }()
+	assert.Eventually(t, controller.Ready, time.Second, time.Millisecond)
+
+	go func() {
+		resp, err := http.Get("http://" + srv.Addr)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after the grace period")
+	}
+}
+
+func TestRunServersListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	err = runServers(context.Background(), 0, time.Second, &http.Server{Addr: ln.Addr().String()})
+	assert.Error(t, err)
+}
+
+func TestHeartbeat(t *testing.T) {
+	handler := heartbeat("/")(http.NotFoundHandler())
+
+	controller.SetReady(true)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	controller.SetReady(false)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/other", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brave-intl/bat-go/libs/logging"
+	"github.com/rs/zerolog"
+
+	"github.com/brave/go-translate/config"
+)
+
+// certCheckInterval bounds how often the certificate files are checked for
+// changes, as every TLS handshake asks for the certificate.
+var certCheckInterval = time.Second
+
+// fileVersion identifies the content of a file without reading it.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statVersion(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// certificate serves the key pair at certFile and keyFile, reloading it when
+// either file changes so that certificates can be rotated without a restart.
+// The last valid key pair keeps being served while the files are invalid,
+// e.g. when only one of them was replaced yet.
+type certificate struct {
+	certFile string
+	keyFile  string
+	logger   *zerolog.Logger
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certVersion fileVersion
+	keyVersion  fileVersion
+	lastCheck   time.Time
+}
+
+// newCertificate loads the key pair, which must be valid.
+func newCertificate(ctx context.Context, certFile, keyFile string) (*certificate, error) {
+	c := &certificate{certFile: certFile, keyFile: keyFile, logger: logging.FromContext(ctx)}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reload loads the key pair if either file changed.
+func (c *certificate) reload() error {
+	certVersion, err := statVersion(c.certFile)
+	if err != nil {
+		return fmt.Errorf("error reading TLS certificate: %v", err)
+	}
+	keyVersion, err := statVersion(c.keyFile)
+	if err != nil {
+		return fmt.Errorf("error reading TLS key: %v", err)
+	}
+	if c.cert != nil && certVersion == c.certVersion && keyVersion == c.keyVersion {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS key pair: %v", err)
+	}
+	c.cert, c.certVersion, c.keyVersion = &cert, certVersion, keyVersion
+	c.logger.Info().
+		Str("cert_file", c.certFile).
+		Str("fingerprint", fingerprint(&cert)).
+		Msg("Loaded TLS certificate")
+	return nil
+}
+
+// GetCertificate returns the current key pair, as tls.Config.GetCertificate.
+func (c *certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.lastCheck) >= certCheckInterval {
+		c.lastCheck = time.Now()
+		if err := c.reload(); err != nil {
+			c.logger.Error().Err(err).Msg("Error reloading TLS certificate, keeping the current one")
+		}
+	}
+	return c.cert, nil
+}
+
+// selfSignedCertificate generates a certificate for localhost, valid for a
+// month.
+func selfSignedCertificate() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("error generating serial number: %v", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"go-translate development"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certificate: %v", err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// fingerprint returns the SHA-256 fingerprint of the leaf certificate.
+func fingerprint(cert *tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	return hex.EncodeToString(sum[:])
+}
+
+// newTLSConfig returns the TLS configuration of the API server, or nil if TLS
+// is disabled.
+func newTLSConfig(ctx context.Context, conf *config.TLSConfig) (*tls.Config, error) {
+	if !conf.Enabled() {
+		return nil, nil
+	}
+	tlsConf := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if conf.SelfSigned {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return nil, fmt.Errorf("error generating self-signed certificate: %v", err)
+		}
+		logging.FromContext(ctx).Warn().
+			Str("fingerprint", fingerprint(cert)).
+			Msg("Serving a self-signed certificate, for local development only")
+		tlsConf.Certificates = []tls.Certificate{*cert}
+	} else {
+		cert, err := newCertificate(ctx, conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.GetCertificate = cert.GetCertificate
+	}
+	return tlsConf, nil
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brave/go-translate/config"
+	"github.com/brave/go-translate/controller"
+)
+
+// writeKeyPair writes a new self-signed key pair to dir.
+func writeKeyPair(t *testing.T, dir string) *tls.Certificate {
+	cert, err := selfSignedCertificate()
+	assert.NoError(t, err)
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	assert.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0o600))
+	return cert
+}
+
+func TestCertificateReload(t *testing.T) {
+	interval := certCheckInterval
+	certCheckInterval = 0
+	t.Cleanup(func() { certCheckInterval = interval })
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeKeyPair(t, dir)
+
+	cert, err := newCertificate(context.Background(), certFile, keyFile)
+	assert.NoError(t, err)
+	served, err := cert.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, fingerprint(first), fingerprint(served))
+
+	// rotated certificates are served without a restart
+	second := writeKeyPair(t, dir)
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, later, later))
+	served, _ = cert.GetCertificate(nil)
+	assert.Equal(t, fingerprint(second), fingerprint(served))
+
+	// an invalid key pair keeps the current one
+	assert.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+	served, _ = cert.GetCertificate(nil)
+	assert.Equal(t, fingerprint(second), fingerprint(served))
+
+	_, err = newCertificate(context.Background(), certFile, keyFile)
+	assert.ErrorContains(t, err, "error loading TLS key pair")
+}
+
+func TestNewTLSConfigDisabled(t *testing.T) {
+	tlsConf, err := newTLSConfig(context.Background(), &config.TLSConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConf)
+}
+
+func TestRunServersTLS(t *testing.T) {
+	tlsConf, err := newTLSConfig(context.Background(), &config.TLSConfig{SelfSigned: true})
+	assert.NoError(t, err)
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	srv := &http.Server{
+		Addr:      freeAddr(t),
+		TLSConfig: tlsConf,
+		Protocols: protocols,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- runServers(ctx, 0, time.Second, srv) }()
+	assert.Eventually(t, controller.Ready, time.Second, time.Millisecond)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(tlsConf.Certificates[0].Leaf)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + srv.Addr)
+	if assert.NoError(t, err) {
+		_ = resp.Body.Close()
+		assert.Equal(t, 2, resp.ProtoMajor)
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
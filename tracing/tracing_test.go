@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/brave/go-translate/config"
+)
+
+func setupTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return exporter
+}
+
+func TestSetupNone(t *testing.T) {
+	shutdown, err := Setup(context.Background(), &config.TracingConfig{Exporter: config.TracingNone, SampleRatio: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), &config.TracingConfig{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := setupTestProvider(t)
+
+	var upstream http.Header
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Start(r.Context(), "child")
+		upstream = http.Header{}
+		Inject(ctx, upstream)
+		RecordError(span, errors.New("failed"))
+		span.End()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/translate_a/t", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 2) {
+		child, server := spans[0], spans[1]
+		// the incoming trace is continued
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+		assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+		assert.Equal(t, codes.Error, server.Status.Code)
+
+		assert.Equal(t, server.SpanContext.SpanID(), child.Parent.SpanID())
+		assert.Equal(t, codes.Error, child.Status.Code)
+		// the child span is propagated to the upstream request
+		assert.Contains(t, upstream.Get("traceparent"), child.SpanContext.SpanID().String())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -27,6 +28,12 @@ var (
 	},
 		[]string{"to_lang", "from_lang"},
 	)
+	sameLangProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "translate_same_language_requests_total",
+		Help: "The total number of requests answered without translation because source and target language match",
+	},
+		[]string{"reason"},
+	)
 )
 
 // RequestBody represents JSON format of Lingvanex requests.
@@ -69,6 +76,70 @@ type RequestBody struct {
 type LingvanexResponseBody struct {
 	SourceText     []string `json:"sourceText"`
 	TranslatedText []string `json:"translatedText"`
+	// DetectedLanguage is only present for auto-detect requests. Depending on
+	// the Lingvanex version it is either a single object or one object per
+	// segment, so it is decoded lazily by detectedLanguages.
+	DetectedLanguage json.RawMessage `json:"detectedLanguage,omitempty"`
+}
+
+// DetectedLanguage represents the language detected by Lingvanex for
+// auto-detect requests.
+type DetectedLanguage struct {
+	Language string  `json:"language"`
+	Score    float64 `json:"score"`
+}
+
+// detectedLanguages returns the languages detected by Lingvanex, accepting
+// both the single object and the per-segment array format.
+func (b *LingvanexResponseBody) detectedLanguages() []DetectedLanguage {
+	if len(b.DetectedLanguage) == 0 {
+		return nil
+	}
+	var list []DetectedLanguage
+	if err := json.Unmarshal(b.DetectedLanguage, &list); err == nil {
+		return list
+	}
+	var single DetectedLanguage
+	if err := json.Unmarshal(b.DetectedLanguage, &single); err == nil {
+		return []DetectedLanguage{single}
+	}
+	return nil
+}
+
+// normalizeLanguageCode maps a Google or Lingvanex language code to the
+// Lingvanex code used for comparisons, so that aliases such as iw and he
+// compare equal.
+func normalizeLanguageCode(code string) string {
+	if lnxCode, err := language.ToLnxLanguageCode(code); err == nil {
+		return lnxCode
+	}
+	return code
+}
+
+// IsSameLanguage reports whether the Google format source and target language
+// codes refer to the same Lingvanex language. It always returns false for
+// auto-detect requests.
+func IsSameLanguage(from, to string) bool {
+	if from == "auto" || from == "" || to == "" {
+		return false
+	}
+	return normalizeLanguageCode(from) == normalizeLanguageCode(to)
+}
+
+// ToSameLanguageResponseBody returns a Google format response body echoing the
+// input segments of the translate request unchanged.
+func ToSameLanguageResponseBody(r *http.Request) ([]byte, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+	qVals := r.PostForm["q"]
+	if qVals == nil {
+		qVals = []string{}
+	}
+
+	sameLangProcessed.With(prometheus.Labels{"reason": "requested"}).Inc()
+	return json.Marshal(qVals)
 }
 
 // GetLanguageParams extracts source and target language parameters from the request
@@ -86,7 +157,8 @@ func GetLanguageParams(r *http.Request) (string, string, error) {
 }
 
 // ToLingvanexRequest parses the input Google format translate request and
-// return a corresponding Lingvanex format request.
+// return a corresponding Lingvanex format request. The returned request
+// inherits the context of the input request, so it is cancelled together with it.
 func ToLingvanexRequest(r *http.Request, serverURL string) (*http.Request, bool, error) {
 	lnxURL := serverURL
 
@@ -140,7 +212,7 @@ func ToLingvanexRequest(r *http.Request, serverURL string) (*http.Request, bool,
 	}
 
 	// Create the HTTP request
-	req, err := http.NewRequest("POST", u.String(), bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(r.Context(), "POST", u.String(), bytes.NewBuffer(body))
 	if err != nil {
 		return nil, false, err
 	}
@@ -152,14 +224,47 @@ func ToLingvanexRequest(r *http.Request, serverURL string) (*http.Request, bool,
 }
 
 // ToGoogleResponseBody parses the input Lingvanex response and return the JSON
-// response body in Google format.
-func ToGoogleResponseBody(body []byte, _ bool) ([]byte, error) {
+// response body in Google format. The response must hold one segment for each
+// of the segments of the translate request.
+// For auto-detect requests where Lingvanex detected that every segment is
+// already in the target language, the source segments are returned unchanged.
+func ToGoogleResponseBody(body []byte, isAuto bool, to string, segments int) ([]byte, error) {
 	// Parse Lnx response body
 	var lnxResp LingvanexResponseBody
 	err := json.Unmarshal(body, &lnxResp)
 	if err != nil {
 		return nil, err
 	}
+	if lnxResp.TranslatedText == nil {
+		return nil, errors.New("missing translatedText")
+	}
+	if len(lnxResp.TranslatedText) != segments {
+		return nil, fmt.Errorf("got %d translated segments for %d", len(lnxResp.TranslatedText), segments)
+	}
+
+	if isAuto && isDetectedAsTarget(&lnxResp, to) {
+		if len(lnxResp.SourceText) != segments {
+			return nil, fmt.Errorf("got %d source segments for %d", len(lnxResp.SourceText), segments)
+		}
+		sameLangProcessed.With(prometheus.Labels{"reason": "detected"}).Inc()
+		return json.Marshal(lnxResp.SourceText)
+	}
 
 	return json.Marshal(lnxResp.TranslatedText)
 }
+
+// isDetectedAsTarget reports whether Lingvanex detected the target language
+// for all of the source segments in the response.
+func isDetectedAsTarget(lnxResp *LingvanexResponseBody, to string) bool {
+	detected := lnxResp.detectedLanguages()
+	if len(detected) == 0 {
+		return false
+	}
+	target := normalizeLanguageCode(to)
+	for _, d := range detected {
+		if normalizeLanguageCode(d.Language) != target {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package translate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSameLanguage(t *testing.T) {
+	assert.True(t, IsSameLanguage("en", "en"))
+	assert.True(t, IsSameLanguage("iw", "he"))
+	assert.True(t, IsSameLanguage("zh-CN", "zh-Hans"))
+	assert.False(t, IsSameLanguage("zh-CN", "zh-TW"))
+	assert.False(t, IsSameLanguage("en", "es"))
+	assert.False(t, IsSameLanguage("auto", "en"))
+}
+
+func TestToSameLanguageResponseBody(t *testing.T) {
+	form := url.Values{"q": []string{"Hello", "<b>World</b>"}}
+	r := httptest.NewRequest(http.MethodPost, "/translate_a/t?sl=en&tl=en", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := ToSameLanguageResponseBody(r)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `["Hello","<b>World</b>"]`, string(body))
+}
+
+func TestToGoogleResponseBody(t *testing.T) {
+	t.Run("translated", func(t *testing.T) {
+		lnxBody := []byte(`{"sourceText":["Hallo"],"translatedText":["Hello"]}`)
+		body, err := ToGoogleResponseBody(lnxBody, false, "en", 1)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `["Hello"]`, string(body))
+	})
+
+	t.Run("auto detected other language", func(t *testing.T) {
+		lnxBody := []byte(`{"sourceText":["Hallo"],"translatedText":["Hello"],"detectedLanguage":{"language":"de","score":1.0}}`)
+		body, err := ToGoogleResponseBody(lnxBody, true, "en", 1)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `["Hello"]`, string(body))
+	})
+
+	t.Run("auto detected target language", func(t *testing.T) {
+		lnxBody := []byte(`{"sourceText":["Shalom"],"translatedText":["Shalom!"],"detectedLanguage":[{"language":"he","score":1.0}]}`)
+		body, err := ToGoogleResponseBody(lnxBody, true, "iw", 1)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `["Shalom"]`, string(body))
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, lnxBody := range []string{
+			`{}`,
+			`null`,
+			`{"sourceText":["Hallo","Welt"],"translatedText":null}`,
+			`{"sourceText":["Hallo","Welt"],"translatedText":[]}`,
+			`{"sourceText":["Hallo","Welt"],"translatedText":["Hello"]}`,
+			`{"sourceText":["Hallo","Welt"],"translatedText":["Hello","World","!"]}`,
+		} {
+			body, err := ToGoogleResponseBody([]byte(lnxBody), false, "en", 2)
+			assert.Error(t, err, lnxBody)
+			assert.Nil(t, body, lnxBody)
+		}
+
+		// the source segments are returned for detected target languages
+		lnxBody := []byte(`{"sourceText":["Shalom"],"translatedText":["Shalom!","Shalom!"],"detectedLanguage":{"language":"he","score":1.0}}`)
+		_, err := ToGoogleResponseBody(lnxBody, true, "iw", 2)
+		assert.ErrorContains(t, err, "got 1 source segments for 2")
+	})
+}